@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	app "github.com/ingeniousambivert/fiber-bootstrapped/src/app"
 	core "github.com/ingeniousambivert/fiber-bootstrapped/src/core"
@@ -11,6 +16,17 @@ func main() {
 	server := core.Build()
 	app.Init(server)
 
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		ctx, cancel := context.WithTimeout(context.Background(), core.TimeoutInSeconds*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("server:error: failed to shutdown server %s", err)
+		}
+	}()
+
 	err := server.Boot()
 	if err != nil {
 		log.Fatalf("server:error: failed to start server %s", err)
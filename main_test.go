@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	app "github.com/ingeniousambivert/fiber-bootstrapped/src/app"
+	core "github.com/ingeniousambivert/fiber-bootstrapped/src/core"
+)
+
+var server *core.Server
+
+func TestMain(m *testing.M) {
+	os.Setenv("DATABASE_ADAPTER", "memory")
+	os.Setenv("JWT_SECRET", "secret")
+	os.Setenv("MAILER_FROM", "mailer@example.com")
+	os.Setenv("AUDIENCE", "http://localhost")
+	dir, err := os.MkdirTemp("", "fiber-bootstrapped")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("ENV=test\n"), 0o600); err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	server = core.Build()
+	app.Init(server)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func request(t *testing.T, method, path, token string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := server.Engine.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	out := map[string]interface{}{}
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &out); err != nil {
+			t.Fatalf("%s %s returned %s", method, path, raw)
+		}
+	}
+	return resp.StatusCode, out
+}
+
+func signup(t *testing.T, email string) string {
+	t.Helper()
+	status, out := request(t, "POST", "/api/v1/users", "", map[string]string{
+		"firstname": "Jane",
+		"lastname":  "Doe",
+		"email":     email,
+		"password":  "password1",
+	})
+	if status != 201 {
+		t.Fatalf("signup returned %d %v", status, out)
+	}
+	id, _ := out["_id"].(string)
+	if id == "" {
+		t.Fatalf("signup returned no _id %v", out)
+	}
+	return id
+}
+
+func login(t *testing.T, email string) string {
+	t.Helper()
+	status, out := request(t, "POST", "/api/v1/authentication", "", map[string]string{
+		"email":    email,
+		"password": "password1",
+	})
+	if status != 200 {
+		t.Fatalf("login returned %d %v", status, out)
+	}
+	token, _ := out["token"].(string)
+	if token == "" {
+		t.Fatalf("login returned no token %v", out)
+	}
+	return token
+}
+
+func TestSignup(t *testing.T) {
+	status, out := request(t, "POST", "/api/v1/users", "", map[string]string{
+		"firstname": "Jane",
+		"lastname":  "Doe",
+		"email":     "Jane@Example.com",
+		"password":  "password1",
+	})
+	if status != 201 {
+		t.Fatalf("signup returned %d %v", status, out)
+	}
+	if out["email"] != "jane@example.com" || out["firstname"] != "Jane" || out["role"] != "user" {
+		t.Errorf("signup returned %v", out)
+	}
+	for _, field := range []string{"password", "verify_token", "reset_token"} {
+		if _, ok := out[field]; ok {
+			t.Errorf("signup returned the hidden field %s", field)
+		}
+	}
+
+	status, out = request(t, "POST", "/api/v1/users", "", map[string]string{
+		"firstname": "Jane",
+		"lastname":  "Doe",
+		"email":     "jane@example.com",
+		"password":  "password1",
+	})
+	if status != 409 || out["message"] != "email already exists" {
+		t.Errorf("duplicate signup returned %d %v", status, out)
+	}
+
+	bulk := []map[string]string{
+		{"firstname": "Bulk", "lastname": "One", "email": "bulk1@example.com", "password": "password1"},
+		{"firstname": "Bulk", "lastname": "Two", "email": "bulk2@example.com", "password": "password1"},
+	}
+	if status, out := request(t, "POST", "/api/v1/users", "", bulk); status != 403 {
+		t.Errorf("bulk signup returned %d %v", status, out)
+	}
+	if status, out := request(t, "POST", "/api/v1/authentication", "", map[string]string{"email": "bulk1@example.com", "password": "password1"}); status != 404 {
+		t.Errorf("login of a bulk signup returned %d %v", status, out)
+	}
+
+	status, out = request(t, "POST", "/api/v1/users", "", map[string]string{
+		"firstname": "Jane",
+		"email":     "not-an-email",
+		"password":  "short",
+	})
+	if status != 422 {
+		t.Fatalf("invalid signup returned %d %v", status, out)
+	}
+	if errs, _ := out["errors"].([]interface{}); len(errs) != 3 {
+		t.Errorf("invalid signup returned the errors %v", out["errors"])
+	}
+}
+
+func TestLogin(t *testing.T) {
+	id := signup(t, "login@example.com")
+
+	status, out := request(t, "POST", "/api/v1/authentication", "", map[string]string{
+		"email":    "login@example.com",
+		"password": "password2",
+	})
+	if status != 401 || out["message"] != "invalid password" {
+		t.Errorf("login with a wrong password returned %d %v", status, out)
+	}
+
+	status, out = request(t, "POST", "/api/v1/authentication", "", map[string]string{
+		"email":    "Login@Example.com",
+		"password": "password1",
+	})
+	if status != 200 {
+		t.Fatalf("login returned %d %v", status, out)
+	}
+	if out["id"] != id || out["token"] == "" {
+		t.Errorf("login returned %v", out)
+	}
+
+	status, out = request(t, "POST", "/api/v1/authentication", "", map[string]string{
+		"email":    "nobody@example.com",
+		"password": "password1",
+	})
+	if status != 404 {
+		t.Errorf("login of an unknown user returned %d %v", status, out)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	id := signup(t, "owner@example.com")
+	token := login(t, "owner@example.com")
+	other := signup(t, "other@example.com")
+
+	if status, out := request(t, "GET", "/api/v1/users/"+id, "", nil); status != 401 {
+		t.Errorf("get without a token returned %d %v", status, out)
+	}
+
+	status, out := request(t, "GET", "/api/v1/users/"+id, token, nil)
+	if status != 200 {
+		t.Fatalf("get returned %d %v", status, out)
+	}
+	if out["_id"] != id || out["email"] != "owner@example.com" {
+		t.Errorf("get returned %v", out)
+	}
+	if _, ok := out["password"]; ok {
+		t.Error("get returned the password")
+	}
+
+	if status, out := request(t, "GET", "/api/v1/users/"+other, token, nil); status != 404 {
+		t.Errorf("get of another user returned %d %v", status, out)
+	}
+	if status, out := request(t, "GET", "/api/v1/users", token, nil); status != 403 {
+		t.Errorf("find as a user returned %d %v", status, out)
+	}
+
+	status, out = request(t, "PATCH", "/api/v1/users/"+id, token, map[string]string{"firstname": "Janet"})
+	if status != 200 {
+		t.Fatalf("patch returned %d %v", status, out)
+	}
+	if out["firstname"] != "Janet" || out["lastname"] != "Doe" {
+		t.Errorf("patch returned %v", out)
+	}
+
+	status, out = request(t, "PUT", "/api/v1/users/"+id, token, map[string]string{"firstname": "Jo", "lastname": "Roe"})
+	if status != 200 {
+		t.Fatalf("update returned %d %v", status, out)
+	}
+	if out["firstname"] != "Jo" || out["lastname"] != "Roe" || out["email"] != "owner@example.com" {
+		t.Errorf("update returned %v", out)
+	}
+
+	status, out = request(t, "DELETE", "/api/v1/users/"+id, token, nil)
+	if status != 200 || out["_id"] != id {
+		t.Fatalf("remove returned %d %v", status, out)
+	}
+	if status, out := request(t, "GET", "/api/v1/users/"+id, token, nil); status != 404 {
+		t.Errorf("get of a removed user returned %d %v", status, out)
+	}
+}
+
+func admin(t *testing.T, email string) string {
+	t.Helper()
+	signup(t, email)
+	users, err := server.App.Service("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := users.Handler.Patch(map[string]interface{}{"email": email}, map[string]interface{}{"role": "admin"}, options.FindOneAndUpdate())
+	if result.Exception != nil {
+		t.Fatal(result.Exception)
+	}
+	return login(t, email)
+}
+
+func TestUsersAdmin(t *testing.T) {
+	token := admin(t, "admin@example.com")
+	id := signup(t, "member@example.com")
+
+	status, out := request(t, "GET", "/api/v1/users?email=member@example.com", token, nil)
+	if status != 200 {
+		t.Fatalf("find returned %d %v", status, out)
+	}
+	data, _ := out["data"].([]interface{})
+	if out["total"] != float64(1) || len(data) != 1 {
+		t.Fatalf("find returned %v", out)
+	}
+	if user, _ := data[0].(map[string]interface{}); user["_id"] != id {
+		t.Errorf("find returned %v", data[0])
+	}
+
+	status, out = request(t, "GET", "/api/v1/users/"+id, token, nil)
+	if status != 200 || out["_id"] != id {
+		t.Errorf("get as an admin returned %d %v", status, out)
+	}
+	if _, ok := out["archived_at"]; ok || out["archived"] != false {
+		t.Errorf("get of an active user returned the archive fields %v", out)
+	}
+	if createdAt, _ := out["created_at"].(string); createdAt == "" || strings.HasPrefix(createdAt, "0001") {
+		t.Errorf("get returned the created_at %v", out["created_at"])
+	}
+}
+
+func TestUsersQueryWhitelist(t *testing.T) {
+	token := admin(t, "whitelist@example.com")
+	if status, out := request(t, "GET", "/api/v1/users?email=whitelist@example.com&$select=email&$sort[created_at]=-1", token, nil); status != 200 {
+		t.Fatalf("find returned %d %v", status, out)
+	}
+	for _, query := range []string{
+		"password=x",
+		"password[$exists]=true",
+		"reset_token[$ne]=x",
+		"$or[0][email]=whitelist@example.com&$or[1][reset_token]=x",
+		"$select=email,password",
+		"$select=reset_token",
+		"$sort[password]=1",
+		"$sort[verify_token]=1",
+	} {
+		if status, out := request(t, "GET", "/api/v1/users?"+query, token, nil); status != 400 {
+			t.Errorf("find with %s returned %d %v", query, status, out)
+		}
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	id := signup(t, "reset@example.com")
+	patched, err := server.App.Subscribe("users", core.EventPatched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer patched.Unsubscribe()
+	auth, err := server.App.Subscribe("auth", core.EventPatched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer auth.Unsubscribe()
+
+	status, out := request(t, "PATCH", "/api/v1/authentication", "", map[string]interface{}{
+		"action": "SendPasswordReset",
+		"data":   map[string]string{"email": "reset@example.com"},
+	})
+	link, _ := out["link"].(string)
+	_, token, _ := strings.Cut(link, "?token=")
+	if status != 200 || token == "" {
+		t.Fatalf("password reset returned %d %v", status, out)
+	}
+
+	reset := map[string]interface{}{
+		"action": "PasswordResetComplete",
+		"data":   map[string]string{"token": token, "newPassword": "password2"},
+	}
+	if status, out := request(t, "PATCH", "/api/v1/authentication", "", reset); status != 200 {
+		t.Fatalf("password reset completion returned %d %v", status, out)
+	}
+	if status, out := request(t, "PATCH", "/api/v1/authentication", "", reset); status != 404 {
+		t.Errorf("password reset with a used token returned %d %v", status, out)
+	}
+	if status, out := request(t, "POST", "/api/v1/authentication", "", map[string]string{"email": "reset@example.com", "password": "password1"}); status != 401 {
+		t.Errorf("login with the old password returned %d %v", status, out)
+	}
+	if status, out := request(t, "POST", "/api/v1/authentication", "", map[string]string{"email": "reset@example.com", "password": "password2"}); status != 200 || out["id"] != id {
+		t.Errorf("login with the new password returned %d %v", status, out)
+	}
+
+	for i := 0; i < 2; i++ {
+		message := <-patched.C
+		if event, _ := message.Data.(core.ServiceEvent); event.Document["_id"] == nil || event.User != id {
+			t.Errorf("users patched event %v", message.Data)
+		}
+	}
+	select {
+	case message := <-auth.C:
+		t.Errorf("auth published %v", message)
+	default:
+	}
+}
+
+func TestUsersArchive(t *testing.T) {
+	token := admin(t, "archivist@example.com")
+	id := signup(t, "archived@example.com")
+	own := login(t, "archived@example.com")
+
+	if status, out := request(t, "DELETE", "/api/v1/users/"+id, own, nil); status != 200 {
+		t.Fatalf("remove returned %d %v", status, out)
+	}
+	if status, out := request(t, "POST", "/api/v1/authentication", "", map[string]string{"email": "archived@example.com", "password": "password1"}); status != 404 {
+		t.Errorf("login of an archived user returned %d %v", status, out)
+	}
+	if status, out := request(t, "PATCH", "/api/v1/users/"+id+"/restore", own, nil); status != 403 {
+		t.Errorf("restore by the user returned %d %v", status, out)
+	}
+	status, out := request(t, "GET", "/api/v1/users/"+id+"?$archived=true", token, nil)
+	if status != 200 || out["archived"] != true || out["archived_at"] == nil {
+		t.Errorf("get of an archived user returned %d %v", status, out)
+	}
+
+	status, out = request(t, "PATCH", "/api/v1/users/"+id+"/restore", token, nil)
+	if status != 200 || out["archived"] != false {
+		t.Fatalf("restore returned %d %v", status, out)
+	}
+	if _, ok := out["archived_at"]; ok {
+		t.Errorf("restore returned the archived_at %v", out["archived_at"])
+	}
+	login(t, "archived@example.com")
+
+	if status, out := request(t, "DELETE", "/api/v1/users/"+id+"/purge", own, nil); status != 403 {
+		t.Errorf("purge by the user returned %d %v", status, out)
+	}
+	if status, out := request(t, "DELETE", "/api/v1/users/"+id+"/purge", token, nil); status != 200 {
+		t.Fatalf("purge returned %d %v", status, out)
+	}
+	if status, out := request(t, "GET", "/api/v1/users/"+id+"?$archived=true", token, nil); status != 404 {
+		t.Errorf("get of a purged user returned %d %v", status, out)
+	}
+}
@@ -1 +1,29 @@
 package events
+
+import (
+	"github.com/gofiber/fiber/v2/log"
+
+	users_build "github.com/ingeniousambivert/fiber-bootstrapped/src/app/services/users/build"
+	"github.com/ingeniousambivert/fiber-bootstrapped/src/core"
+)
+
+func Subscribe(app *core.App) {
+	for _, eventType := range []string{core.EventCreated, core.EventPatched, core.EventUpdated, core.EventRemoved, core.EventRestored, core.EventPurged} {
+		subscription, err := app.Subscribe(users_build.Name, eventType)
+		if err != nil {
+			log.Errorf("failed to subscribe to %s %s events : %s", users_build.Name, eventType, err.Error())
+			continue
+		}
+		go audit(subscription)
+	}
+}
+
+func audit(subscription *core.Subscription) {
+	for message := range subscription.C {
+		event, ok := message.Data.(core.ServiceEvent)
+		if !ok {
+			continue
+		}
+		log.Infof("%s %s %v by %s", event.Service, event.Type, event.Document["_id"], event.User)
+	}
+}
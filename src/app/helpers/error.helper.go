@@ -3,30 +3,32 @@ package helpers
 import (
 	"errors"
 
-	"github.com/ingeniousambivert/fiber-bootstrapped/src/app/utils"
 	"github.com/ingeniousambivert/fiber-bootstrapped/src/core"
 )
 
 func NotFound(m string) *core.ServerError {
-	return &core.ServerError{Status: utils.HttpStatusNotFound, Title: "not-found", Message: m}
+	return core.NotFound(m)
 }
 
 func BadRequest(m string) *core.ServerError {
-	return &core.ServerError{Status: utils.HttpStatusBadRequest, Title: "bad-request", Message: m}
+	return core.BadRequest(m)
 }
 
 func Unauthorized(m string) *core.ServerError {
-	return &core.ServerError{Status: utils.HttpStatusUnauthorized, Title: "unauthorized", Message: m}
+	return core.Unauthorized(m)
 }
 func Forbidden(m string) *core.ServerError {
-	return &core.ServerError{Status: utils.HttpStatusForbidden, Title: "forbidden", Message: m}
+	return core.Forbidden(m)
 }
 func Conflict(m string) *core.ServerError {
-	return &core.ServerError{Status: utils.HttpStatusConflict, Title: "conflict", Message: m}
+	return core.Conflict(m)
+}
+func PreconditionFailed(m string) *core.ServerError {
+	return core.PreconditionFailed(m)
 }
 
 func Unexpected(m string) *core.ServerError {
-	return &core.ServerError{Status: utils.HttpStatusInternalServerError, Title: "internal-server", Message: m}
+	return core.Unexpected(m)
 }
 
 func CreateError(message string) error {
@@ -22,6 +22,7 @@ func Validate(authenticate bool, authorize bool) fiber.Handler {
 				claims := auth.Claims.(jwt.MapClaims)
 				c.Locals("user", claims["id"].(string))
 				role := claims["role"].(string)
+				c.Locals("role", role)
 				if role == "admin" {
 					return c.Next()
 				} else {
@@ -40,6 +41,8 @@ func Validate(authenticate bool, authorize bool) fiber.Handler {
 				auth := c.Locals("auth").(*jwt.Token)
 				claims := auth.Claims.(jwt.MapClaims)
 				c.Locals("user", claims["id"].(string))
+				role, _ := claims["role"].(string)
+				c.Locals("role", role)
 				return c.Next()
 			},
 		})
@@ -3,7 +3,6 @@ package hooks
 import (
 	"time"
 
-	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -12,22 +11,13 @@ import (
 	auth_manage_schema "github.com/ingeniousambivert/fiber-bootstrapped/src/app/schemas/auth/manage"
 	users_schema "github.com/ingeniousambivert/fiber-bootstrapped/src/app/schemas/users"
 	auth_utils "github.com/ingeniousambivert/fiber-bootstrapped/src/app/services/auth/utils"
-	"github.com/ingeniousambivert/fiber-bootstrapped/src/app/utils"
 	"github.com/ingeniousambivert/fiber-bootstrapped/src/core"
 )
 
-func AddVerfication(params map[string]interface{}) (interface{}, error) {
-	c, ok := params["ctx"].(*fiber.Ctx)
+func AddVerfication(hc *core.HookContext) error {
+	result, ok := hc.Result.(users_schema.Response)
 	if !ok {
-		return nil, helpers.Unexpected("missing ctx")
-	}
-	h, ok := params["handler"].(core.Handler)
-	if !ok {
-		return nil, helpers.Unexpected("missing handler")
-	}
-	result := c.Locals("response").(users_schema.Response)
-	if utils.IsNil(result) {
-		return nil, helpers.Unexpected("missing/invalid : c.Locals('response')")
+		return helpers.Unexpected("missing/invalid result")
 	}
 
 	payload := map[string]interface{}{
@@ -40,36 +30,28 @@ func AddVerfication(params map[string]interface{}) (interface{}, error) {
 
 	filter := map[string]interface{}{"_id": result.ID}
 	patchOptions := options.FindOneAndUpdateOptions{}
-	patchResponse := h.Patch(filter, payload, &patchOptions)
-	if patchResponse.Exception != nil {
-		if patchResponse.Exception == mongo.ErrNoDocuments {
-			return nil, helpers.NotFound("document not found")
+	updatedUser, err := core.Typed[users_schema.Response](hc.Service.Handler.As(hc.Params.User)).Patch(filter, payload, &patchOptions)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return helpers.NotFound("document not found")
 		} else {
-			return nil, helpers.Unexpected(patchResponse.Exception.Error())
+			return helpers.Unexpected(err.Error())
 		}
 	}
-	var updatedUser users_schema.Response
-	patchResponse.Result.Decode(&updatedUser)
-	response := updatedUser
-	c.Locals("response", response)
-	return response, nil
-
+	hc.Result = updatedUser
+	return nil
 }
 
-func NotifyVerfication(params map[string]interface{}) (interface{}, error) {
-	c, ok := params["ctx"].(*fiber.Ctx)
-	if !ok {
-		return nil, helpers.Unexpected("missing ctx")
-	}
+func NotifyVerfication(hc *core.HookContext) error {
 	payload := auth_manage_schema.Request{
 		Action: auth_manage_schema.SendEmailVerification,
 		Data:   map[string]interface{}{},
 	}
-	response := c.Locals("response").(users_schema.Response)
-	if utils.IsNil(response) {
-		return nil, helpers.Unexpected("missing/invalid : c.Locals('response')")
+	response, ok := hc.Result.(users_schema.Response)
+	if !ok {
+		return helpers.Unexpected("missing/invalid result")
 	}
 	payload.Data["user"] = response
-	result, err := auth_utils.Notifier(payload)
-	return result, err
+	_, err := auth_utils.Notifier(payload)
+	return err
 }
@@ -3,7 +3,7 @@ package schemas
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
 type Request struct {
-	Email    string `json:"email" bson:"email" binding:"required"`
+	Email    string `json:"email" bson:"email" binding:"required,email"`
 	Password string `json:"password" bson:"password" binding:"required,min=8"`
 }
 type Response struct {
@@ -11,11 +11,48 @@ const (
 	PasswordUpdate            Action = "PasswordUpdate"
 )
 
+func (a Action) Values() []string {
+	return []string{
+		string(SendEmailVerification),
+		string(EmailVerificationComplete),
+		string(SendPasswordReset),
+		string(PasswordResetComplete),
+		string(EmailUpdate),
+		string(PasswordUpdate),
+	}
+}
+
 type Request struct {
-	Action Action                 `json:"action" bson:"action" binding:"required"`
+	Action Action                 `json:"action" bson:"action" binding:"required,enum"`
 	Data   map[string]interface{} `json:"data" bson:"data" binding:"required"`
 }
 
+// EmailData is the data of SendEmailVerification and SendPasswordReset.
+type EmailData struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type EmailVerificationData struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type PasswordResetData struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required,min=8"`
+}
+
+type EmailUpdateData struct {
+	Email    string `json:"email" binding:"required,email"`
+	NewEmail string `json:"newEmail" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+type PasswordUpdateData struct {
+	Email       string `json:"email" binding:"required,email"`
+	Password    string `json:"password" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required,min=8"`
+}
+
 type Response struct {
 	Link string `json:"link" bson:"link"`
 }
@@ -13,13 +13,17 @@ const (
 	AdminRole Role = "admin"
 )
 
+func (r Role) Values() []string {
+	return []string{string(UserRole), string(AdminRole)}
+}
+
 type Request struct {
 	Firstname     string      `json:"firstname" bson:"firstname" binding:"required"`
 	Lastname      string      `json:"lastname" bson:"lastname" binding:"required"`
-	Email         string      `json:"email" bson:"email" binding:"required"`
+	Email         string      `json:"email" bson:"email" binding:"required,email"`
 	Password      string      `json:"password" bson:"password" binding:"required,min=8"`
 	Archived      bool        `json:"archived" bson:"archived"`
-	Role          Role        `json:"role" bson:"role"`
+	Role          Role        `json:"role" bson:"role" binding:"omitempty,enum"`
 	Verified      bool        `json:"verified" bson:"verified"`
 	VerifyToken   string      `json:"verify_token" bson:"verify_token"`
 	VerifyExpires time.Time   `json:"verify_expires" bson:"verify_expires"`
@@ -30,21 +34,31 @@ type Request struct {
 	Metadata      interface{} `json:"metadata" bson:"metadata"`
 }
 
+// Changes are the fields clients replace and patch.
+type Changes struct {
+	Firstname string      `json:"firstname" bson:"firstname" binding:"required"`
+	Lastname  string      `json:"lastname" bson:"lastname" binding:"required"`
+	Metadata  interface{} `json:"metadata" bson:"metadata"`
+}
+
+// Raw is a stored user. Its access tags rule what clients read and write.
 type Raw struct {
-	ID            primitive.ObjectID `json:"_id" bson:"_id"`
-	Firstname     string             `json:"firstname" bson:"firstname" `
-	Lastname      string             `json:"lastname" bson:"lastname" `
-	Email         string             `json:"email" bson:"email"`
-	Password      string             `json:"password" bson:"password"`
-	Archived      bool               `json:"archived" bson:"archived"`
-	Role          Role               `json:"role" bson:"role"`
-	Verified      bool               `json:"verified" bson:"verified"`
-	VerifyToken   string             `json:"verify_token,omitempty" bson:"verify_token"`
-	VerifyExpires time.Time          `json:"verify_expires,omitempty" bson:"verify_expires"`
-	ResetToken    string             `json:"reset_token,omitempty" bson:"reset_token"`
-	ResetExpires  time.Time          `json:"reset_expires,omitempty" bson:"reset_expires"`
-	CreatedAt     time.Time          `json:"created_at,omitempty" bson:"created_at"`
-	UpdatedAt     time.Time          `json:"updated_at,omitempty" bson:"updated_at"`
+	ID            primitive.ObjectID `json:"_id" bson:"_id" binding:"required" access:"readonly"`
+	Firstname     string             `json:"firstname" bson:"firstname" binding:"required"`
+	Lastname      string             `json:"lastname" bson:"lastname" binding:"required"`
+	Email         string             `json:"email" bson:"email" binding:"required,email" access:"writeonce"`
+	Password      string             `json:"password" bson:"password" binding:"required" access:"writeonce,hidden"`
+	Archived      bool               `json:"archived" bson:"archived" access:"readonly,admin"`
+	ArchivedAt    time.Time          `json:"archived_at,omitempty" bson:"archived_at,omitempty" access:"readonly,admin"`
+	Role          Role               `json:"role" bson:"role" binding:"required,enum" access:"readonly"`
+	Verified      bool               `json:"verified" bson:"verified" access:"readonly"`
+	VerifyToken   string             `json:"verify_token,omitempty" bson:"verify_token" access:"readonly,hidden"`
+	VerifyExpires time.Time          `json:"verify_expires,omitempty" bson:"verify_expires" access:"readonly,hidden"`
+	ResetToken    string             `json:"reset_token,omitempty" bson:"reset_token" access:"readonly,hidden"`
+	ResetExpires  time.Time          `json:"reset_expires,omitempty" bson:"reset_expires" access:"readonly,hidden"`
+	CreatedAt     time.Time          `json:"created_at,omitempty" bson:"created_at" access:"readonly"`
+	UpdatedAt     time.Time          `json:"updated_at,omitempty" bson:"updated_at" access:"readonly"`
+	Version       int64              `json:"version" bson:"version" access:"readonly"`
 	Metadata      interface{}        `json:"metadata" bson:"metadata"`
 }
 
@@ -54,14 +68,16 @@ type Response struct {
 	Lastname      string             `json:"lastname" bson:"lastname" `
 	Email         string             `json:"email" bson:"email"`
 	Archived      bool               `json:"archived" bson:"archived"`
+	ArchivedAt    *time.Time         `json:"archived_at,omitempty" bson:"archived_at,omitempty"`
 	Role          Role               `json:"role" bson:"role"`
 	Verified      bool               `json:"verified" bson:"verified"`
 	VerifyToken   string             `json:"verify_token,omitempty" bson:"verify_token"`
-	VerifyExpires time.Time          `json:"verify_expires,omitempty" bson:"verify_expires"`
+	VerifyExpires *time.Time         `json:"verify_expires,omitempty" bson:"verify_expires"`
 	ResetToken    string             `json:"reset_token,omitempty" bson:"reset_token"`
-	ResetExpires  time.Time          `json:"reset_expires,omitempty" bson:"reset_expires"`
-	CreatedAt     time.Time          `json:"created_at,omitempty" bson:"created_at"`
-	UpdatedAt     time.Time          `json:"updated_at,omitempty" bson:"updated_at"`
+	ResetExpires  *time.Time         `json:"reset_expires,omitempty" bson:"reset_expires"`
+	CreatedAt     *time.Time         `json:"created_at,omitempty" bson:"created_at"`
+	UpdatedAt     *time.Time         `json:"updated_at,omitempty" bson:"updated_at"`
+	Version       int64              `json:"version" bson:"version"`
 	Metadata      interface{}        `json:"metadata" bson:"metadata"`
 }
 
@@ -72,14 +88,24 @@ func GenerateResponse(raw *Raw) Response {
 		Lastname:      raw.Lastname,
 		Email:         raw.Email,
 		Archived:      raw.Archived,
+		ArchivedAt:    timeOrNil(raw.ArchivedAt),
 		Role:          raw.Role,
 		Verified:      raw.Verified,
 		VerifyToken:   raw.VerifyToken,
-		VerifyExpires: raw.VerifyExpires,
+		VerifyExpires: timeOrNil(raw.VerifyExpires),
 		ResetToken:    raw.ResetToken,
-		ResetExpires:  raw.ResetExpires,
-		CreatedAt:     raw.CreatedAt,
-		UpdatedAt:     raw.UpdatedAt,
+		ResetExpires:  timeOrNil(raw.ResetExpires),
+		CreatedAt:     timeOrNil(raw.CreatedAt),
+		UpdatedAt:     timeOrNil(raw.UpdatedAt),
+		Version:       raw.Version,
 		Metadata:      raw.Metadata,
 	}
 }
+
+// timeOrNil drops zero times from responses.
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -1,8 +1,8 @@
 package auth
 
 import (
-	"context"
-
+	auth_schema "github.com/ingeniousambivert/fiber-bootstrapped/src/app/schemas/auth"
+	auth_manage_schema "github.com/ingeniousambivert/fiber-bootstrapped/src/app/schemas/auth/manage"
 	controllers "github.com/ingeniousambivert/fiber-bootstrapped/src/app/services/auth/controllers"
 	"github.com/ingeniousambivert/fiber-bootstrapped/src/core"
 )
@@ -12,18 +12,17 @@ var Path = "/authentication"
 var Service *core.Service
 var Hooks core.Hooks
 
+// Build has no entity, the controllers store through the users service.
 func Build(server *core.Server) *core.Service {
-	ae := core.Entity{
-		Ctx:        context.Background(),
-		Collection: server.Database.Collection("users"),
-	}
-
 	Service = core.Create().
 		SetName(Name).
 		SetPath(Path).
-		SetEntity(ae).
-		AddPublicRoute("CREATE", controllers.Create).
-		AddPublicRoute("PATCH", controllers.Patch)
+		SetRequestSchema(core.MethodCreate, auth_schema.Request{}).
+		SetRequestSchema(core.MethodPatch, auth_manage_schema.Request{}).
+		SetResponseSchema(core.MethodCreate, auth_schema.Response{}).
+		SetResponseSchema(core.MethodPatch, auth_manage_schema.Response{}).
+		AddPublicRoute(core.MethodCreate, controllers.Create).
+		AddPublicRoute(core.MethodPatch, controllers.Patch)
 
 	return Service
 }
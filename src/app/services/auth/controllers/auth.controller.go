@@ -4,7 +4,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -15,45 +14,43 @@ import (
 	auth_manage_schema "github.com/ingeniousambivert/fiber-bootstrapped/src/app/schemas/auth/manage"
 	users_schema "github.com/ingeniousambivert/fiber-bootstrapped/src/app/schemas/users"
 	auth_utils "github.com/ingeniousambivert/fiber-bootstrapped/src/app/services/auth/utils"
+	users_build "github.com/ingeniousambivert/fiber-bootstrapped/src/app/services/users/build"
 	"github.com/ingeniousambivert/fiber-bootstrapped/src/app/utils"
 	"github.com/ingeniousambivert/fiber-bootstrapped/src/core"
 )
 
-func Create(params map[string]interface{}) error {
+// users reads the stored users, password included, through the users service.
+func users(cc *core.ControllerContext) core.Handler {
+	return users_build.Service.Handler.WithContext(cc.Context)
+}
+
+// patchUser writes through the users service, at the version user was read at.
+func patchUser(cc *core.ControllerContext, user users_schema.Raw, update core.Document) (users_schema.Response, error) {
+	id := user.ID.Hex()
+	params := core.Params{User: id, Version: &user.Version}
+	return core.Calls[users_schema.Response](users_build.Service).Patch(cc.Context, id, core.Operators{"$set": update}, params)
+}
+
+func Create(cc *core.ControllerContext) error {
 	config := core.Configuration()
-	c, ok := params["ctx"].(*fiber.Ctx)
-	if !ok {
-		return helpers.Unexpected("missing ctx")
-	}
-	h, ok := params["handler"].(core.Handler)
-	if !ok {
-		return helpers.Unexpected("missing handler")
-	}
+	h := users(cc)
 
 	payload := new(auth_schema.Request)
-	err := c.BodyParser(payload)
+	err := cc.BodyParser(payload)
 	if err != nil {
 		return helpers.Unexpected(err.Error())
 	}
-	if !utils.IsString(payload.Email) {
-		return helpers.BadRequest("missing payload: email")
-	}
-	if !utils.IsString(payload.Password) {
-		return helpers.BadRequest("missing payload: password")
-	}
 
 	filter := map[string]interface{}{"email": strings.ToLower(payload.Email)}
 	findOptions := options.FindOneOptions{}
-	findResponse := h.Get(filter, &findOptions)
-	if findResponse.Exception != nil {
-		if findResponse.Exception == mongo.ErrNoDocuments {
+	user, err := core.Typed[users_schema.Raw](h).Get(filter, &findOptions)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
 			return helpers.NotFound("document not found")
 		} else {
-			return helpers.Unexpected(findResponse.Exception.Error())
+			return helpers.Unexpected(err.Error())
 		}
 	}
-	var user users_schema.Raw
-	findResponse.Result.Decode(&user)
 	err = utils.VerifyPassword(user.Password, payload.Password)
 	if err != nil {
 		return helpers.Unauthorized("invalid password")
@@ -70,281 +67,203 @@ func Create(params map[string]interface{}) error {
 		return helpers.Unexpected("could not generate token")
 	}
 	response := auth_schema.Response{Token: jwt, ID: user.ID}
-	c.Locals("response", response)
-	return c.
-		Status(utils.HttpStatusOK).
-		JSON(response)
+	return cc.Respond(utils.HttpStatusOK, response)
 }
 
-func Patch(params map[string]interface{}) error {
-	c, ok := params["ctx"].(*fiber.Ctx)
-	if !ok {
-		return helpers.Unexpected("missing ctx")
-	}
-	h, ok := params["handler"].(core.Handler)
-	if !ok {
-		return helpers.Unexpected("missing handler")
-	}
+func Patch(cc *core.ControllerContext) error {
+	h := users(cc)
 	var user users_schema.Raw
+	var response users_schema.Response
 	payload := new(auth_manage_schema.Request)
-	err := c.BodyParser(payload)
+	err := cc.BodyParser(payload)
 	if err != nil {
 		return helpers.Unexpected(err.Error())
 	}
-	if !utils.IsString(payload.Action) {
-		return helpers.BadRequest("missing/invalid payload: action")
-	}
-	if !utils.IsMap(payload.Data) {
-		return helpers.BadRequest("missing/invalid payload: data")
-	}
 
 	switch payload.Action {
 	case auth_manage_schema.EmailVerificationComplete:
 		{
-			filter := map[string]interface{}{}
-			if !utils.IsString(payload.Data["token"].(string)) {
-				return helpers.BadRequest("missing/invalid payload: token")
+			var data auth_manage_schema.EmailVerificationData
+			if err := core.Decode("data", payload.Data, &data); err != nil {
+				return err
 			}
-			filter["verify_token"] = payload.Data["token"]
+			filter := map[string]interface{}{"verify_token": data.Token}
 			findOptions := options.FindOneOptions{}
-			findResponse := h.Get(filter, &findOptions)
-			if findResponse.Exception != nil {
-				if findResponse.Exception == mongo.ErrNoDocuments {
+			user, err = core.Typed[users_schema.Raw](h).Get(filter, &findOptions)
+			if err != nil {
+				if err == mongo.ErrNoDocuments {
 					return helpers.NotFound("user not found")
 				}
-				return helpers.Unexpected(findResponse.Exception.Error())
-			}
-			err = findResponse.Result.Decode(&user)
-			if err != nil {
 				return helpers.Unexpected(err.Error())
 			}
 			if utils.IsPast(user.VerifyExpires) {
 				return helpers.Unauthorized("expired token")
 			}
-			_, err = utils.VerifyUUIDs(user.VerifyToken, payload.Data["token"].(string))
+			_, err = utils.VerifyUUIDs(user.VerifyToken, data.Token)
 			if err != nil {
 				return helpers.Unauthorized("invalid token")
 			}
-			patchOptions := options.FindOneAndUpdateOptions{}
-			update := map[string]interface{}{
+			update := core.Document{
 				"verified":       true,
 				"verify_token":   nil,
 				"verify_expires": nil,
 			}
-			patchResponse := h.Patch(filter, update, &patchOptions)
-			if patchResponse.Exception != nil {
-				if patchResponse.Exception == mongo.ErrNoDocuments {
-					return helpers.NotFound("document not found")
-				} else {
-					return helpers.Unexpected(patchResponse.Exception.Error())
-				}
+			response, err = patchUser(cc, user, update)
+			if err != nil {
+				return err
 			}
-			patchResponse.Result.Decode(&user)
 		}
 
 	case auth_manage_schema.SendPasswordReset:
 		{
-			filter := map[string]interface{}{}
-			if !utils.IsString(payload.Data["email"].(string)) {
-				return helpers.BadRequest("missing/invalid payload: data['email']")
+			var data auth_manage_schema.EmailData
+			if err := core.Decode("data", payload.Data, &data); err != nil {
+				return err
 			}
-			filter["email"] = payload.Data["email"].(string)
+			filter := map[string]interface{}{"email": data.Email}
 			findOptions := options.FindOneOptions{}
-			findResponse := h.Get(filter, &findOptions)
-			if findResponse.Exception != nil {
-				if findResponse.Exception == mongo.ErrNoDocuments {
+			user, err = core.Typed[users_schema.Raw](h).Get(filter, &findOptions)
+			if err != nil {
+				if err == mongo.ErrNoDocuments {
 					return helpers.NotFound("user not found")
 				}
-				return helpers.Unexpected(findResponse.Exception.Error())
+				return helpers.Unexpected(err.Error())
 			}
-			findResponse.Result.Decode(&user)
 
-			patchOptions := options.FindOneAndUpdateOptions{}
-			update := map[string]interface{}{
+			update := core.Document{
 				"reset_token":   uuid.New().String(),
 				"reset_expires": time.Now().Add(time.Hour * 24),
 			}
-			patchResponse := h.Patch(filter, update, &patchOptions)
-			if patchResponse.Exception != nil {
-				if patchResponse.Exception == mongo.ErrNoDocuments {
-					return helpers.NotFound("document not found")
-				} else {
-					return helpers.Unexpected(patchResponse.Exception.Error())
-				}
+			response, err = patchUser(cc, user, update)
+			if err != nil {
+				return err
 			}
-			patchResponse.Result.Decode(&user)
 		}
 
 	case auth_manage_schema.PasswordResetComplete:
 		{
-			filter := map[string]interface{}{}
-			if !utils.IsString(payload.Data["token"].(string)) {
-				return helpers.BadRequest("missing/invalid payload: token")
+			var data auth_manage_schema.PasswordResetData
+			if err := core.Decode("data", payload.Data, &data); err != nil {
+				return err
 			}
-			if !utils.IsString(payload.Data["newPassword"]) {
-				return helpers.BadRequest("missing/invalid payload: newPassword")
-			}
-
-			filter["reset_token"] = payload.Data["token"].(string)
+			filter := map[string]interface{}{"reset_token": data.Token}
 			findOptions := options.FindOneOptions{}
-			findResponse := h.Get(filter, &findOptions)
-			if findResponse.Exception != nil {
-				if findResponse.Exception == mongo.ErrNoDocuments {
+			user, err = core.Typed[users_schema.Raw](h).Get(filter, &findOptions)
+			if err != nil {
+				if err == mongo.ErrNoDocuments {
 					return helpers.NotFound("user not found")
 				}
-				return helpers.Unexpected(findResponse.Exception.Error())
+				return helpers.Unexpected(err.Error())
 			}
-			findResponse.Result.Decode(&user)
 
 			if utils.IsPast(user.ResetExpires) {
 				return helpers.Unauthorized("expired token")
 			}
-			_, err = utils.VerifyUUIDs(user.ResetToken, payload.Data["token"].(string))
+			_, err = utils.VerifyUUIDs(user.ResetToken, data.Token)
 			if err != nil {
 				return helpers.Unauthorized("invalid token")
 			}
-			hashedPassword, err := utils.HashPassword(payload.Data["newPassword"].(string))
+			hashedPassword, err := utils.HashPassword(data.NewPassword)
 			if err != nil {
 				return helpers.Unexpected(err.Error())
 			}
-			patchOptions := options.FindOneAndUpdateOptions{}
-			update := map[string]interface{}{
+			update := core.Document{
 				"password":      hashedPassword,
 				"reset_token":   nil,
 				"reset_expires": nil,
 			}
-			patchResponse := h.Patch(filter, update, &patchOptions)
-			if patchResponse.Exception != nil {
-				if patchResponse.Exception == mongo.ErrNoDocuments {
-					return helpers.NotFound("document not found")
-				} else {
-					return helpers.Unexpected(patchResponse.Exception.Error())
-				}
+			response, err = patchUser(cc, user, update)
+			if err != nil {
+				return err
 			}
-			patchResponse.Result.Decode(&user)
 		}
 
 	case auth_manage_schema.EmailUpdate:
 		{
-			filter := map[string]interface{}{}
-			if !utils.IsString(payload.Data["email"]) {
-				return helpers.BadRequest("missing/invalid payload: email")
+			var data auth_manage_schema.EmailUpdateData
+			if err := core.Decode("data", payload.Data, &data); err != nil {
+				return err
 			}
-			if !utils.IsString(payload.Data["newEmail"]) {
-				return helpers.BadRequest("missing/invalid payload: newEmail")
-			}
-			if !utils.IsString(payload.Data["password"]) {
-				return helpers.BadRequest("missing/invalid payload: password")
-			}
-			filter["email"] = payload.Data["email"].(string)
+			filter := map[string]interface{}{"email": data.Email}
 			findOptions := options.FindOneOptions{}
-			findResponse := h.Get(filter, &findOptions)
-			if findResponse.Exception != nil {
-				if findResponse.Exception == mongo.ErrNoDocuments {
+			user, err = core.Typed[users_schema.Raw](h).Get(filter, &findOptions)
+			if err != nil {
+				if err == mongo.ErrNoDocuments {
 					return helpers.NotFound("user not found")
 				}
-				return helpers.Unexpected(findResponse.Exception.Error())
+				return helpers.Unexpected(err.Error())
 			}
-			findResponse.Result.Decode(&user)
-			err = utils.VerifyPassword(user.Password, payload.Data["password"].(string))
+			err = utils.VerifyPassword(user.Password, data.Password)
 			if err != nil {
 				return helpers.Unauthorized("invalid password")
 			}
-			patchOptions := options.FindOneAndUpdateOptions{}
-			update := map[string]interface{}{
+			update := core.Document{
 				"verified":       false,
 				"verify_token":   uuid.New().String(),
 				"verify_expires": time.Now().Add(time.Hour * 168),
-				"email":          strings.ToLower(payload.Data["newEmail"].(string)),
+				"email":          strings.ToLower(data.NewEmail),
 			}
-			patchResponse := h.Patch(filter, update, &patchOptions)
-
-			if patchResponse.Exception != nil {
-				if patchResponse.Exception == mongo.ErrNoDocuments {
-					return helpers.NotFound("document not found")
-				}
-				if er, ok := patchResponse.Exception.(mongo.CommandError); ok && er.Code == 11000 {
-					return helpers.Conflict("email already exists")
-				}
-
-				return helpers.Unexpected(patchResponse.Exception.Error())
+			response, err = patchUser(cc, user, update)
+			if err != nil {
+				return err
 			}
-			patchResponse.Result.Decode(&user)
 		}
 
 	case auth_manage_schema.PasswordUpdate:
 		{
-			filter := map[string]interface{}{}
-			if !utils.IsString(payload.Data["email"]) {
-				return helpers.BadRequest("missing/invalid payload: email")
-			}
-			if !utils.IsString(payload.Data["password"]) {
-				return helpers.BadRequest("missing/invalid payload: password")
-			}
-			if !utils.IsString(payload.Data["newPassword"]) {
-				return helpers.BadRequest("missing/invalid payload: newPassword")
+			var data auth_manage_schema.PasswordUpdateData
+			if err := core.Decode("data", payload.Data, &data); err != nil {
+				return err
 			}
-			filter["email"] = payload.Data["email"].(string)
+			filter := map[string]interface{}{"email": data.Email}
 			findOptions := options.FindOneOptions{}
-			findResponse := h.Get(filter, &findOptions)
-			if findResponse.Exception != nil {
-				if findResponse.Exception == mongo.ErrNoDocuments {
+			user, err = core.Typed[users_schema.Raw](h).Get(filter, &findOptions)
+			if err != nil {
+				if err == mongo.ErrNoDocuments {
 					return helpers.NotFound("user not found")
 				}
-				return helpers.Unexpected(findResponse.Exception.Error())
+				return helpers.Unexpected(err.Error())
 			}
-			findResponse.Result.Decode(&user)
-			err = utils.VerifyPassword(user.Password, payload.Data["password"].(string))
+			err = utils.VerifyPassword(user.Password, data.Password)
 			if err != nil {
 				return helpers.Unauthorized("invalid password")
 			}
-			patchOptions := options.FindOneAndUpdateOptions{}
-			hashedPassword, err := utils.HashPassword(payload.Data["newPassword"].(string))
+			hashedPassword, err := utils.HashPassword(data.NewPassword)
 			if err != nil {
 				return helpers.Unexpected(err.Error())
 			}
-			update := map[string]interface{}{
+			update := core.Document{
 				"password": hashedPassword,
 			}
-			patchResponse := h.Patch(filter, update, &patchOptions)
-			if patchResponse.Exception != nil {
-				if patchResponse.Exception == mongo.ErrNoDocuments {
-					return helpers.NotFound("document not found")
-				} else {
-					return helpers.Unexpected(patchResponse.Exception.Error())
-				}
+			response, err = patchUser(cc, user, update)
+			if err != nil {
+				return err
 			}
-			patchResponse.Result.Decode(&user)
 		}
 	}
 
 	if utils.IsZeroOrNil(user) {
-		filter := map[string]interface{}{}
-		if !utils.IsString(payload.Data["email"].(string)) {
-			return helpers.BadRequest("missing/invalid payload: data['email']")
+		var data auth_manage_schema.EmailData
+		if err := core.Decode("data", payload.Data, &data); err != nil {
+			return err
 		}
-		filter["email"] = payload.Data["email"].(string)
+		filter := map[string]interface{}{"email": data.Email}
 		findOptions := options.FindOneOptions{}
-		findResponse := h.Get(filter, &findOptions)
-		if findResponse.Exception != nil {
-			if findResponse.Exception == mongo.ErrNoDocuments {
+		user, err = core.Typed[users_schema.Raw](h).Get(filter, &findOptions)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
 				return helpers.NotFound("user not found")
 			}
-			return helpers.Unexpected(findResponse.Exception.Error())
+			return helpers.Unexpected(err.Error())
 		}
-		findResponse.Result.Decode(&user)
-
+		response = users_schema.GenerateResponse(&user)
 	}
 
-	payload.Data["user"] = users_schema.GenerateResponse(&user)
+	payload.Data["user"] = response
 	result, err := auth_utils.Notifier(*payload)
 	if err != nil {
 		return err
 	}
-	response := auth_manage_schema.Response{Link: result}
-	c.Locals("response", response)
-	return c.
-		Status(utils.HttpStatusOK).
-		JSON(response)
+	return cc.Respond(utils.HttpStatusOK, auth_manage_schema.Response{Link: result})
 
 }
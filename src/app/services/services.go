@@ -2,6 +2,7 @@ package services
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
 
 	"github.com/ingeniousambivert/fiber-bootstrapped/src/app/helpers"
 	auth "github.com/ingeniousambivert/fiber-bootstrapped/src/app/services/auth/build"
@@ -18,28 +19,29 @@ func BindRouter(server *core.Server) map[string]*core.Service {
 	services[UsersService.Name] = UsersService
 
 	app := server.Engine
-	router := app.Group("/api/v1")
+	router := app.Group(core.APIPrefix)
 
+	var routes core.Router
 	for _, service := range services {
-		for method, route := range service.Router {
-			controller := service.Bind(route.Controller, server)
-			switch method {
-			case "FIND":
-				router.Get(route.Path, helpers.Validate(route.Extras.Authenticate, route.Extras.Authorize), controller)
-			case "GET":
-				router.Get(route.Path, helpers.Validate(route.Extras.Authenticate, route.Extras.Authorize), controller)
-			case "CREATE":
-				router.Post(route.Path, helpers.Validate(route.Extras.Authenticate, route.Extras.Authorize), controller)
-			case "PATCH":
-				router.Patch(route.Path, helpers.Validate(route.Extras.Authenticate, route.Extras.Authorize), controller)
-			case "PUT":
-				router.Put(route.Path, helpers.Validate(route.Extras.Authenticate, route.Extras.Authorize), controller)
-			case "DELETE":
-				router.Delete(route.Path, helpers.Validate(route.Extras.Authenticate, route.Extras.Authorize), controller)
-			}
+		routes = append(routes, service.Router...)
+	}
+	if err := routes.Verify(); err != nil {
+		log.Fatalf("failed to bind the services %s", err)
+	}
+
+	for _, service := range services {
+		if err := service.InstallValidator(); err != nil {
+			log.Errorf("failed to install the schema validator of service %s %s", service.Name, err)
+		}
+		for _, route := range service.Router {
+			controller := service.Bind(route, server)
+			router.Add(core.Verbs[route.Method], route.Path, helpers.Validate(route.Extras.Authenticate, route.Extras.Authorize), controller)
 		}
 	}
 
+	router.Get(core.SchemasPath, core.SchemasController(services))
+	router.Get(core.SchemasPath+"/:service", core.SchemasController(services))
+
 	app.Use(func(c *fiber.Ctx) error {
 		return c.Status(404).SendString("route not found")
 	})
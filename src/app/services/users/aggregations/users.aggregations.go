@@ -0,0 +1,28 @@
+package users
+
+import (
+	"errors"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func Roles(params map[string]string) (mongo.Pipeline, error) {
+	match := bson.M{}
+	if value, ok := params["verified"]; ok {
+		verified, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, errors.New("invalid params: verified")
+		}
+		match["verified"] = verified
+	}
+	return mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"role": "$role", "verified": "$verified"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id.role", Value: 1}, {Key: "_id.verified", Value: 1}}}},
+	}, nil
+}
@@ -3,11 +3,11 @@ package users
 import (
 	"context"
 
-	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/log"
 
 	"github.com/ingeniousambivert/fiber-bootstrapped/src/app/hooks"
 	schema "github.com/ingeniousambivert/fiber-bootstrapped/src/app/schemas/users"
+	aggregations "github.com/ingeniousambivert/fiber-bootstrapped/src/app/services/users/aggregations"
 	controllers "github.com/ingeniousambivert/fiber-bootstrapped/src/app/services/users/controllers"
 	"github.com/ingeniousambivert/fiber-bootstrapped/src/app/utils"
 	"github.com/ingeniousambivert/fiber-bootstrapped/src/core"
@@ -16,107 +16,63 @@ import (
 var Name = "users"
 var Path = "/users"
 var Service *core.Service
-var Hooks core.Hooks
+var Hooks = core.Hooks{
+	After: core.HookChain{
+		core.MethodCreate: {verify},
+	},
+}
+
+func verify(hc *core.HookContext) error {
+	err := hooks.AddVerfication(hc)
+	if err != nil {
+		log.Errorf("failed to add verification data to user : %s", err.Error())
+		return nil
+	}
+	if utils.IsUUID(hc.Result.(schema.Response).VerifyToken) {
+		err = hooks.NotifyVerfication(hc)
+		if err != nil {
+			log.Errorf("failed to send verification notification to user : %s", err.Error())
+		}
+	}
+	return nil
+}
 
 func Build(server *core.Server) *core.Service {
 	ue := core.Entity{
-		Ctx:        context.Background(),
-		Collection: server.Database.Collection("users"),
+		Ctx:     context.Background(),
+		Adapter: server.Storage.Collection("users", schema.Raw{}),
+	}
+	if err := utils.CreateIndex(ue, "email"); err != nil {
+		log.Errorf("failed to create the users email index : %s", err.Error())
 	}
 
 	Service = core.Create().
 		SetName(Name).
 		SetPath(Path).
 		SetEntity(ue).
-		AddProtectedRoute("FIND", controllers.Find).
-		AddPrivateRoute("GET", controllers.Get, "/:id").
-		AddPublicRoute("CREATE", controllers.Create).
-		AddPrivateRoute("PATCH", controllers.Patch, "/:id").
-		AddPrivateRoute("DELETE", controllers.Delete, "/:id").
-		SetHooks(core.Hooks{
-			Before: func(c *fiber.Ctx) error {
-				switch c.Method() {
-				case "GET":
-					{
-
-					}
-				case "POST":
-					{
-
-					}
-				case "PATCH":
-					{
-
-					}
-				case "DELETE":
-					{
-
-					}
-				}
-				return nil
-			},
-			After: func(c *fiber.Ctx) error {
-				switch c.Method() {
-				case "GET":
-					{
-
-					}
-				case "POST":
-					{
-						if utils.IsNil(c.Locals("response")) {
-							log.Error("missing params: c.Locals('response')")
-						} else {
-							params := map[string]interface{}{
-								"ctx":     c,
-								"entity":  Service.Entity,
-								"handler": Service.Handler,
-							}
-							updatedUser, err := hooks.AddVerfication(params)
-							if err != nil {
-								log.Errorf("failed to add verification data to user : %s", err.Error())
-							}
-							if utils.IsUUID(updatedUser.(schema.Response).VerifyToken) {
-								_, err = hooks.NotifyVerfication(params)
-								if err != nil {
-									log.Errorf("failed to send verification notification to user : %s", err.Error())
-								}
-							}
-
-						}
-					}
-				case "PATCH":
-					{
-
-					}
-				case "DELETE":
-					{
-
-					}
-				}
-				return nil
-			},
-			OnError: func(c *fiber.Ctx) error {
-				switch c.Method() {
-				case "GET":
-					{
-
-					}
-				case "POST":
-					{
-
-					}
-				case "PATCH":
-					{
-
-					}
-				case "DELETE":
-					{
-
-					}
-				}
-				return nil
-			},
-		})
+		SetSchema(schema.Raw{}).
+		SetRequestSchema(core.MethodCreate, schema.Request{}).
+		SetRequestSchema(core.MethodUpdate, schema.Changes{}).
+		SetRequestSchema(core.MethodPatch, core.Partial(schema.Changes{})).
+		SetResponseSchema(core.MethodGet, schema.Response{}).
+		SetResponseSchema(core.MethodCreate, schema.Response{}).
+		SetResponseSchema(core.MethodUpdate, schema.Response{}).
+		SetResponseSchema(core.MethodPatch, schema.Response{}).
+		SetResponseSchema(core.MethodDelete, schema.Response{}).
+		SetStorageValidation().
+		SetQueryable("_id", "firstname", "lastname", "email", "role", "verified", "archived", "archived_at", "created_at", "updated_at").
+		SetSortable("firstname", "lastname", "email", "role", "created_at", "updated_at").
+		SetPagination(core.Pagination{Default: 25, Max: 100}).
+		SetResource(controllers.Resource).
+		AddProtectedRoute(core.MethodFind, controllers.Resource.Find).
+		AddProtectedRoute(core.MethodFind, core.AggregateController, core.AggregatePath).
+		AddPublicRoute(core.MethodCreate, controllers.Resource.Create).
+		AddAggregation("roles", aggregations.Roles).
+		SetMulti(core.MethodCreate, core.MethodPatch, core.MethodDelete).
+		SetSoftDelete().
+		SetTimestamps().
+		SetVersioning().
+		SetHooks(Hooks)
 
 	return Service
 }
@@ -9,18 +9,11 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/ingeniousambivert/fiber-bootstrapped/src/core"
 )
 
-const (
-	Limit int64 = 25
-	Skip  int64 = 0
-)
 const (
 	HttpStatusContinue                      int = 100
 	HttpStatusSwitchingProtocols            int = 101
@@ -87,10 +80,7 @@ const (
 )
 
 func CreateIndex(e core.Entity, indexKey string) error {
-	opt := options.Index()
-	opt.SetUnique(true)
-	index := mongo.IndexModel{Keys: bson.M{indexKey: 1}, Options: opt}
-	_, err := e.Collection.Indexes().CreateOne(e.Ctx, index)
+	err := e.Adapter.CreateIndex(e.Ctx, indexKey, true)
 	if err != nil {
 		return errors.New("could not create index in mongodb")
 	}
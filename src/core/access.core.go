@@ -0,0 +1,320 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// AccessTag is the struct tag of the access rules of a schema field.
+const AccessTag = "access"
+
+const (
+	// AccessReadOnly fields are never written by clients.
+	AccessReadOnly = "readonly"
+	// AccessWriteOnce fields are only written by clients on create.
+	AccessWriteOnce = "writeonce"
+	// AccessHidden fields are never sent to clients.
+	AccessHidden = "hidden"
+	// AccessAdmin fields are only read and written by admins.
+	AccessAdmin = "admin"
+)
+
+type FieldAccess struct {
+	ReadOnly  bool
+	WriteOnce bool
+	Hidden    bool
+	Admin     bool
+}
+
+// Access holds the rules of the fields of a schema by bson and json name.
+type Access map[string]FieldAccess
+
+// AccessOf reads the access tags of a schema struct.
+func AccessOf(schema interface{}) Access {
+	access := Access{}
+	t := reflect.TypeOf(schema)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return access
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get(AccessTag)
+		if !field.IsExported() || tag == "" {
+			continue
+		}
+		rules := FieldAccess{}
+		for _, rule := range strings.Split(tag, ",") {
+			switch strings.TrimSpace(rule) {
+			case AccessReadOnly:
+				rules.ReadOnly = true
+			case AccessWriteOnce:
+				rules.WriteOnce = true
+			case AccessHidden:
+				rules.Hidden = true
+			case AccessAdmin:
+				rules.Admin = true
+			}
+		}
+		for _, key := range []string{"bson", "json"} {
+			name := strings.Split(field.Tag.Get(key), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+				if key == "bson" {
+					name = strings.ToLower(name)
+				}
+			}
+			access[name] = rules
+		}
+	}
+	return access
+}
+
+func rootField(path string) string {
+	root, _, _ := strings.Cut(path, ".")
+	return root
+}
+
+// Writable reports whether a principal may write a field on create or on change.
+func (a Access) Writable(field string, create bool, admin bool) bool {
+	rules, ok := a[rootField(field)]
+	if !ok {
+		return true
+	}
+	return !rules.ReadOnly && (create || !rules.WriteOnce) && (admin || !rules.Admin)
+}
+
+// Visible reports whether a principal may read a field.
+func (a Access) Visible(field string, admin bool) bool {
+	rules, ok := a[rootField(field)]
+	if !ok {
+		return true
+	}
+	return !rules.Hidden && (admin || !rules.Admin)
+}
+
+// Input fails with a 403 ServerError on the first field or JSON Patch
+// operation of decoded JSON data a principal may not write.
+func (a Access) Input(data interface{}, create bool, admin bool) error {
+	switch value := data.(type) {
+	case map[string]interface{}:
+		for key := range value {
+			if !a.Writable(key, create, admin) {
+				return Forbidden(fmt.Sprintf("field %s is not writable", key))
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if operation, ok := item.(map[string]interface{}); ok && isPatchOperation(operation) {
+				if err := a.checkOperation(operation, admin); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := a.Input(item, create, admin); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func isPatchOperation(operation map[string]interface{}) bool {
+	_, op := operation["op"]
+	_, path := operation["path"]
+	return op && path
+}
+
+func pointerField(pointer interface{}) string {
+	path, _ := pointer.(string)
+	tokens, err := parsePointer(path)
+	if err != nil || len(tokens) == 0 {
+		return ""
+	}
+	return tokens[0]
+}
+
+func (a Access) checkOperation(operation map[string]interface{}, admin bool) error {
+	path := pointerField(operation["path"])
+	from := pointerField(operation["from"])
+	var read, written []string
+	switch operation["op"] {
+	case "test":
+		read = []string{path}
+	case "move":
+		read, written = []string{from}, []string{path, from}
+	case "copy":
+		read, written = []string{from}, []string{path}
+	default:
+		written = []string{path}
+	}
+	for _, field := range append(read, written...) {
+		if field == "" && len(a) > 0 {
+			return Forbidden("json patch operations on the whole document are not allowed")
+		}
+	}
+	for _, field := range read {
+		if !a.Visible(field, admin) {
+			return Forbidden(fmt.Sprintf("field %s is not readable", field))
+		}
+	}
+	for _, field := range written {
+		if !a.Writable(field, false, admin) {
+			return Forbidden(fmt.Sprintf("field %s is not writable", field))
+		}
+	}
+	return nil
+}
+
+type documents interface {
+	documents()
+}
+
+func (p Page[T]) documents() {}
+func (b Bulk[T]) documents() {}
+
+// Output drops from the JSON value of a result the fields a principal may not
+// see.
+func (a Access) Output(result interface{}, admin bool) (interface{}, error) {
+	value, documents, err := jsonDocuments(result)
+	if err != nil {
+		return nil, err
+	}
+	for _, document := range documents {
+		a.outputDocument(document, admin)
+	}
+	return value, nil
+}
+
+func jsonDocuments(result interface{}) (interface{}, []map[string]interface{}, error) {
+	body, err := json.Marshal(result)
+	if err != nil {
+		return nil, nil, err
+	}
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, nil, err
+	}
+	_, wrapped := result.(documents)
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if wrapped {
+			return value, listDocuments(v["data"], false), nil
+		}
+		return value, []map[string]interface{}{v}, nil
+	case []interface{}:
+		return value, listDocuments(v, wrapped), nil
+	}
+	return value, nil, nil
+}
+
+func listDocuments(list interface{}, wrapped bool) []map[string]interface{} {
+	values, _ := list.([]interface{})
+	documents := make([]map[string]interface{}, 0, len(values))
+	for _, item := range values {
+		document, ok := item.(map[string]interface{})
+		if wrapped && ok {
+			document, ok = document["data"].(map[string]interface{})
+		}
+		if ok {
+			documents = append(documents, document)
+		}
+	}
+	return documents
+}
+
+func (a Access) outputDocument(document map[string]interface{}, admin bool) {
+	for key := range document {
+		if !a.Visible(key, admin) {
+			delete(document, key)
+		}
+	}
+}
+
+// fields rejects $ keys, which the handler would take for update operators.
+func fields(data interface{}) error {
+	items, ok := data.([]interface{})
+	if !ok {
+		items = []interface{}{data}
+	}
+	for _, item := range items {
+		document, _ := item.(map[string]interface{})
+		for key := range document {
+			if strings.HasPrefix(key, "$") {
+				return BadRequest(fmt.Sprintf("invalid field %s", key))
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Service) input(method string, p Principal, hc *HookContext) error {
+	if len(s.Access) == 0 || p.Internal {
+		return nil
+	}
+	switch method {
+	case MethodCreate, MethodPatch, MethodUpdate:
+	default:
+		return nil
+	}
+	if unparsed(hc) {
+		return BadRequest("payload must be json")
+	}
+	if hc.Data == nil {
+		return nil
+	}
+	return s.Access.Input(hc.Data, method == MethodCreate, p.Admin())
+}
+
+func (s *Service) output(cc *ControllerContext, result interface{}) (interface{}, error) {
+	var selected bson.M
+	if cc.query != nil {
+		selected = cc.query.Select
+	}
+	if cc.Principal.Internal || (len(s.Access) == 0 && len(selected) == 0) {
+		return result, nil
+	}
+	value, documents, err := jsonDocuments(result)
+	if err != nil {
+		return nil, err
+	}
+	for _, document := range documents {
+		if len(selected) > 0 {
+			project(document, selected)
+		}
+		s.Access.outputDocument(document, cc.Principal.Admin())
+	}
+	return value, nil
+}
+
+// project keeps the selected fields of a document and its _id.
+func project(document map[string]interface{}, selected bson.M) {
+	for key, value := range document {
+		if key == "_id" || selected[key] != nil {
+			continue
+		}
+		nested := bson.M{}
+		for path := range selected {
+			if rest, ok := strings.CutPrefix(path, key+"."); ok {
+				nested[rest] = 1
+			}
+		}
+		if len(nested) == 0 {
+			delete(document, key)
+		} else if subdocument, ok := value.(map[string]interface{}); ok {
+			project(subdocument, nested)
+		}
+	}
+}
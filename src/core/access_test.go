@@ -0,0 +1,123 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAccessOf(t *testing.T) {
+	access := AccessOf(account{})
+	rules := map[string]FieldAccess{
+		"_id":     {ReadOnly: true},
+		"email":   {WriteOnce: true},
+		"secret":  {Hidden: true},
+		"role":    {Admin: true},
+		"version": {ReadOnly: true},
+	}
+	if !reflect.DeepEqual(map[string]FieldAccess(access), rules) {
+		t.Errorf("access is %v", access)
+	}
+	if len(AccessOf(&account{})) != len(rules) || len(AccessOf(1)) != 0 {
+		t.Error("access of a pointer or a non struct schema")
+	}
+}
+
+func TestAccessRules(t *testing.T) {
+	access := AccessOf(account{})
+	writable := []struct {
+		field         string
+		create, admin bool
+		writable      bool
+	}{
+		{"name", false, false, true},
+		{"_id", true, true, false},
+		{"version", false, true, false},
+		{"email", true, false, true},
+		{"email", false, true, false},
+		{"email.domain", false, false, false},
+		{"secret", false, false, true},
+		{"role", true, false, false},
+		{"role", false, true, true},
+	}
+	for _, c := range writable {
+		if access.Writable(c.field, c.create, c.admin) != c.writable {
+			t.Errorf("%s writable on create %t by admin %t is %t", c.field, c.create, c.admin, !c.writable)
+		}
+	}
+	visible := map[string][2]bool{
+		"name":   {true, true},
+		"secret": {false, false},
+		"role":   {false, true},
+	}
+	for field, v := range visible {
+		if access.Visible(field, false) != v[0] || access.Visible(field, true) != v[1] {
+			t.Errorf("%s visibility is wrong", field)
+		}
+	}
+
+	inputs := []struct {
+		data   interface{}
+		create bool
+		ok     bool
+	}{
+		{map[string]interface{}{"name": "a", "email": "a@example.com"}, true, true},
+		{map[string]interface{}{"name": "a", "email": "a@example.com"}, false, false},
+		{map[string]interface{}{"_id": "a"}, true, false},
+		{[]interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{"role": "admin"}}, true, false},
+	}
+	for _, c := range inputs {
+		if err := access.Input(c.data, c.create, false); (err == nil) != c.ok {
+			t.Errorf("input of %v on create %t returned %v", c.data, c.create, err)
+		}
+	}
+}
+
+func TestAccessOutput(t *testing.T) {
+	accounts := newService("accounts", account{}).SetResource(CRUD[account, account]{})
+	engine := serve(InitApp(), accounts)
+	user := []string{"X-User", "user"}
+	admin := []string{"X-User", "admin", "X-Role", AdminRole}
+
+	if status, _, out := send(t, engine, "POST", "/accounts", map[string]interface{}{"name": "a", "role": "admin"}, user...); status != fiber.StatusForbidden {
+		t.Errorf("create of an admin field returned %d %v", status, out)
+	}
+	if status, _, out := send(t, engine, "POST", "/accounts", map[string]interface{}{"name": "a", "version": 7}, user...); status != fiber.StatusForbidden {
+		t.Errorf("create of a readonly field returned %d %v", status, out)
+	}
+	status, _, out := send(t, engine, "POST", "/accounts", map[string]interface{}{"name": "a", "email": "a@example.com", "secret": "s", "tags": []string{}}, user...)
+	created := object(t, out)
+	if status != fiber.StatusCreated || created["email"] != "a@example.com" {
+		t.Fatalf("create returned %d %v", status, out)
+	}
+	for _, field := range []string{"secret", "role"} {
+		if _, ok := created[field]; ok {
+			t.Errorf("create returned the field %s to a user", field)
+		}
+	}
+	path := "/accounts/" + created["_id"].(string)
+
+	status, _, out = send(t, engine, "GET", path, nil, admin...)
+	if document := object(t, out); status != fiber.StatusOK || document["secret"] != nil || document["role"] != "" {
+		t.Errorf("get returned %d %v to an admin", status, out)
+	}
+	status, _, out = send(t, engine, "GET", "/accounts", nil, user...)
+	data, _ := object(t, out)["data"].([]interface{})
+	if status != fiber.StatusOK || len(data) != 1 {
+		t.Fatalf("find returned %d %v", status, out)
+	}
+	if _, ok := object(t, data[0])["role"]; ok {
+		t.Errorf("find returned the role to a user")
+	}
+
+	if status, _, out := send(t, engine, "PATCH", path, map[string]interface{}{"email": "b@example.com"}, user...); status != fiber.StatusForbidden {
+		t.Errorf("patch of a writeonce field returned %d %v", status, out)
+	}
+	if status, _, out := send(t, engine, "PATCH", path, map[string]interface{}{"secret": "t"}, user...); status != fiber.StatusOK {
+		t.Errorf("patch of a hidden field returned %d %v", status, out)
+	}
+	if status, _, out := send(t, engine, "PATCH", path, map[string]interface{}{"role": "admin"}, admin...); status != fiber.StatusOK || object(t, out)["role"] != "admin" {
+		t.Errorf("patch of an admin field by an admin returned %d %v", status, out)
+	}
+}
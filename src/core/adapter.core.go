@@ -0,0 +1,100 @@
+package core
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Adapter is the storage behind a Handler, speaking the mongo query language.
+type Adapter interface {
+	Find(ctx context.Context, filter interface{}, opts FindHandlerOptions) (*mongo.Cursor, error)
+	FindOne(ctx context.Context, filter interface{}, opts GetHandlerOptions) *mongo.SingleResult
+	InsertOne(ctx context.Context, document interface{}, opts CreateHandlerOptions) (*mongo.InsertOneResult, error)
+	InsertMany(ctx context.Context, documents []interface{}, opts CreateManyHandlerOptions) (*mongo.InsertManyResult, error)
+	FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts PatchHandlerOptions) *mongo.SingleResult
+	FindOneAndReplace(ctx context.Context, filter interface{}, replacement interface{}, opts UpdateHandlerOptions) *mongo.SingleResult
+	FindOneAndDelete(ctx context.Context, filter interface{}, opts DeleteHandlerOptions) *mongo.SingleResult
+	CountDocuments(ctx context.Context, filter interface{}, opts CountHandlerOptions) (int64, error)
+	EstimatedDocumentCount(ctx context.Context, opts EstimatedCountHandlerOptions) (int64, error)
+	Aggregate(ctx context.Context, pipeline interface{}, opts AggregateHandlerOptions) (*mongo.Cursor, error)
+	CreateIndex(ctx context.Context, key string, unique bool) error
+}
+
+// namespaceNotFound is the mongo error code of commands on missing collections.
+const namespaceNotFound = 26
+
+type MongoAdapter struct {
+	Collection *mongo.Collection
+}
+
+func NewMongoAdapter(collection *mongo.Collection) *MongoAdapter {
+	return &MongoAdapter{Collection: collection}
+}
+
+func (m *MongoAdapter) Find(ctx context.Context, filter interface{}, opts FindHandlerOptions) (*mongo.Cursor, error) {
+	return m.Collection.Find(ctx, filter, opts)
+}
+
+func (m *MongoAdapter) FindOne(ctx context.Context, filter interface{}, opts GetHandlerOptions) *mongo.SingleResult {
+	return m.Collection.FindOne(ctx, filter, opts)
+}
+
+func (m *MongoAdapter) InsertOne(ctx context.Context, document interface{}, opts CreateHandlerOptions) (*mongo.InsertOneResult, error) {
+	return m.Collection.InsertOne(ctx, document, opts)
+}
+
+func (m *MongoAdapter) InsertMany(ctx context.Context, documents []interface{}, opts CreateManyHandlerOptions) (*mongo.InsertManyResult, error) {
+	return m.Collection.InsertMany(ctx, documents, opts)
+}
+
+func (m *MongoAdapter) FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts PatchHandlerOptions) *mongo.SingleResult {
+	return m.Collection.FindOneAndUpdate(ctx, filter, update, opts)
+}
+
+func (m *MongoAdapter) FindOneAndReplace(ctx context.Context, filter interface{}, replacement interface{}, opts UpdateHandlerOptions) *mongo.SingleResult {
+	return m.Collection.FindOneAndReplace(ctx, filter, replacement, opts)
+}
+
+func (m *MongoAdapter) FindOneAndDelete(ctx context.Context, filter interface{}, opts DeleteHandlerOptions) *mongo.SingleResult {
+	return m.Collection.FindOneAndDelete(ctx, filter, opts)
+}
+
+func (m *MongoAdapter) CountDocuments(ctx context.Context, filter interface{}, opts CountHandlerOptions) (int64, error) {
+	return m.Collection.CountDocuments(ctx, filter, opts)
+}
+
+func (m *MongoAdapter) EstimatedDocumentCount(ctx context.Context, opts EstimatedCountHandlerOptions) (int64, error) {
+	return m.Collection.EstimatedDocumentCount(ctx, opts)
+}
+
+func (m *MongoAdapter) Aggregate(ctx context.Context, pipeline interface{}, opts AggregateHandlerOptions) (*mongo.Cursor, error) {
+	return m.Collection.Aggregate(ctx, pipeline, opts)
+}
+
+func (m *MongoAdapter) CreateIndex(ctx context.Context, key string, unique bool) error {
+	opt := options.Index()
+	opt.SetUnique(unique)
+	index := mongo.IndexModel{Keys: bson.M{key: 1}, Options: opt}
+	_, err := m.Collection.Indexes().CreateOne(ctx, index)
+	return err
+}
+
+// SetValidator installs schema as the $jsonSchema validator of the collection.
+func (m *MongoAdapter) SetValidator(ctx context.Context, schema bson.M) error {
+	validator := bson.M{"$jsonSchema": schema}
+	database, name := m.Collection.Database(), m.Collection.Name()
+	err := database.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: name},
+		{Key: "validator", Value: validator},
+		{Key: "validationLevel", Value: "moderate"},
+	}).Err()
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) && commandErr.Code == namespaceNotFound {
+		return database.CreateCollection(ctx, name, options.CreateCollection().SetValidator(validator).SetValidationLevel("moderate"))
+	}
+	return err
+}
@@ -0,0 +1,382 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Aggregation builds a pipeline from the request parameters.
+type Aggregation func(params map[string]string) (mongo.Pipeline, error)
+
+const AggregatePath = "/aggregate/:name"
+
+type Stage struct {
+	Name  string
+	Value interface{}
+}
+
+// ToStages normalizes a pipeline into its stages, keeping the $sort key order.
+func ToStages(pipeline interface{}) ([]Stage, error) {
+	data, err := bson.Marshal(bson.M{"pipeline": pipeline})
+	if err != nil {
+		return nil, err
+	}
+	var raw struct {
+		Pipeline []bson.D `bson:"pipeline"`
+	}
+	if err := bson.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	stages := make([]Stage, 0, len(raw.Pipeline))
+	for _, stage := range raw.Pipeline {
+		if len(stage) != 1 {
+			return nil, fmt.Errorf("a pipeline stage specification must contain exactly one field")
+		}
+		value := stage[0].Value
+		if stage[0].Key == "$sort" {
+			value, err = toOrderedDocument(value)
+		} else {
+			value, err = normalizeValue(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, Stage{Name: stage[0].Key, Value: value})
+	}
+	return stages, nil
+}
+
+func normalizeValue(v interface{}) (interface{}, error) {
+	document, err := ToDocument(bson.M{"v": v})
+	if err != nil {
+		return nil, err
+	}
+	return document["v"], nil
+}
+
+func evaluate(document Document, expression interface{}) interface{} {
+	switch value := expression.(type) {
+	case string:
+		if strings.HasPrefix(value, "$") {
+			field, _ := lookupField(document, value[1:])
+			return field
+		}
+	case Document:
+		result := Document{}
+		for key, nested := range value {
+			result[key] = evaluate(document, nested)
+		}
+		return result
+	case primitive.A:
+		result := primitive.A{}
+		for _, nested := range value {
+			result = append(result, evaluate(document, nested))
+		}
+		return result
+	}
+	return expression
+}
+
+type accumulator struct {
+	operator string
+	value    interface{}
+	integer  int64
+	float    float64
+	floating bool
+	count    int64
+	items    primitive.A
+	set      bool
+}
+
+func (a *accumulator) add(value interface{}) {
+	switch a.operator {
+	case "$sum", "$avg":
+		switch n := value.(type) {
+		case int32:
+			a.integer += int64(n)
+		case int64:
+			a.integer += n
+		case float64:
+			a.float += n
+			a.floating = true
+		default:
+			return
+		}
+		a.count++
+	case "$min", "$max":
+		if value == nil {
+			return
+		}
+		if !a.set {
+			a.value, a.set = value, true
+			return
+		}
+		if order, ok := compareValues(value, a.value); ok && ((a.operator == "$min" && order < 0) || (a.operator == "$max" && order > 0)) {
+			a.value = value
+		}
+	case "$first":
+		if !a.set {
+			a.value, a.set = value, true
+		}
+	case "$last":
+		a.value = value
+	case "$push":
+		a.items = append(a.items, value)
+	}
+}
+
+func (a *accumulator) result() interface{} {
+	switch a.operator {
+	case "$sum":
+		if a.floating {
+			return a.float + float64(a.integer)
+		}
+		return a.integer
+	case "$avg":
+		if a.count == 0 {
+			return nil
+		}
+		return (a.float + float64(a.integer)) / float64(a.count)
+	case "$push":
+		if a.items == nil {
+			return primitive.A{}
+		}
+		return a.items
+	}
+	return a.value
+}
+
+// canonical orders nested keys so that equal values marshal alike.
+func canonical(v interface{}) interface{} {
+	switch value := v.(type) {
+	case Document:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		ordered := bson.D{}
+		for _, key := range keys {
+			ordered = append(ordered, bson.E{Key: key, Value: canonical(value[key])})
+		}
+		return ordered
+	case primitive.A:
+		items := primitive.A{}
+		for _, item := range value {
+			items = append(items, canonical(item))
+		}
+		return items
+	}
+	return v
+}
+
+func groupKey(v interface{}) (string, error) {
+	data, err := bson.Marshal(bson.D{{Key: "v", Value: canonical(v)}})
+	return string(data), err
+}
+
+func groupDocuments(documents []Document, spec Document) ([]Document, error) {
+	id, ok := spec["_id"]
+	if !ok {
+		return nil, fmt.Errorf("a group specification must include an _id")
+	}
+	fields := map[string]Document{}
+	for field, value := range spec {
+		if field == "_id" {
+			continue
+		}
+		operators, ok := value.(Document)
+		if !ok || len(operators) != 1 {
+			return nil, fmt.Errorf("the field '%s' must be an accumulator object", field)
+		}
+		for operator := range operators {
+			switch operator {
+			case "$sum", "$avg", "$min", "$max", "$first", "$last", "$push":
+			default:
+				return nil, fmt.Errorf("unsupported accumulator %s", operator)
+			}
+		}
+		fields[field] = operators
+	}
+
+	keys := []string{}
+	groups := map[string]Document{}
+	accumulators := map[string]map[string]*accumulator{}
+	for _, document := range documents {
+		value := evaluate(document, id)
+		key, err := groupKey(value)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+			groups[key] = Document{"_id": value}
+			accumulators[key] = map[string]*accumulator{}
+			for field, operators := range fields {
+				for operator := range operators {
+					accumulators[key][field] = &accumulator{operator: operator}
+				}
+			}
+		}
+		for field, operators := range fields {
+			for _, expression := range operators {
+				accumulators[key][field].add(evaluate(document, expression))
+			}
+		}
+	}
+
+	results := make([]Document, 0, len(keys))
+	for _, key := range keys {
+		group := groups[key]
+		for field, accumulator := range accumulators[key] {
+			group[field] = accumulator.result()
+		}
+		results = append(results, group)
+	}
+	return results, nil
+}
+
+func projectStage(documents []Document, spec Document) []Document {
+	projection := Document{}
+	computed := Document{}
+	for key, value := range spec {
+		switch value.(type) {
+		case string, Document:
+			computed[key] = value
+		default:
+			projection[key] = value
+		}
+	}
+	if len(computed) > 0 {
+		for key := range computed {
+			projection[key] = true
+		}
+	}
+	results := make([]Document, 0, len(documents))
+	for _, document := range documents {
+		result := ProjectDocument(document, projection)
+		for key, expression := range computed {
+			setField(result, key, evaluate(document, expression))
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// AggregateDocuments runs the pipeline stages the non-mongo adapters support.
+func AggregateDocuments(documents []Document, stages []Stage) ([]Document, error) {
+	for _, stage := range stages {
+		switch stage.Name {
+		case "$match":
+			filter, ok := stage.Value.(Document)
+			if !ok {
+				return nil, fmt.Errorf("the match filter must be an expression in an object")
+			}
+			matches := []Document{}
+			for _, document := range documents {
+				matched, err := MatchDocument(document, filter)
+				if err != nil {
+					return nil, err
+				}
+				if matched {
+					matches = append(matches, document)
+				}
+			}
+			documents = matches
+		case "$group":
+			spec, ok := stage.Value.(Document)
+			if !ok {
+				return nil, fmt.Errorf("a group's fields must be specified in an object")
+			}
+			grouped, err := groupDocuments(documents, spec)
+			if err != nil {
+				return nil, err
+			}
+			documents = grouped
+		case "$sort":
+			order, ok := stage.Value.(bson.D)
+			if !ok {
+				return nil, fmt.Errorf("the $sort key specification must be an object")
+			}
+			SortDocuments(documents, order)
+		case "$skip", "$limit":
+			n, ok := toFloat(stage.Value)
+			if !ok || n < 0 {
+				return nil, fmt.Errorf("invalid argument to %s stage", stage.Name)
+			}
+			count := int(n)
+			if stage.Name == "$skip" {
+				if count > len(documents) {
+					count = len(documents)
+				}
+				documents = documents[count:]
+			} else if count < len(documents) {
+				documents = documents[:count]
+			}
+		case "$count":
+			field, ok := stage.Value.(string)
+			if !ok || field == "" {
+				return nil, fmt.Errorf("the count field must be a non-empty string")
+			}
+			if len(documents) > 0 {
+				documents = []Document{{field: int32(len(documents))}}
+			}
+		case "$project":
+			spec, ok := stage.Value.(Document)
+			if !ok {
+				return nil, fmt.Errorf("$project specification must be an object")
+			}
+			documents = projectStage(documents, spec)
+		default:
+			return nil, fmt.Errorf("unsupported pipeline stage %s", stage.Name)
+		}
+	}
+	return documents, nil
+}
+
+func cursorOf(documents []Document) (*mongo.Cursor, error) {
+	items := make([]interface{}, 0, len(documents))
+	for _, document := range documents {
+		items = append(items, document)
+	}
+	return mongo.NewCursorFromDocuments(items, nil, nil)
+}
+
+// AggregateController responds with a page of the aggregation named by the route.
+func AggregateController(cc *ControllerContext) error {
+	s := cc.Service
+	aggregation, ok := s.Aggregations[cc.Params.Route["name"]]
+	if !ok {
+		return NotFound("aggregation not found")
+	}
+
+	query := make(map[string]string, len(cc.Params.Query))
+	for key := range cc.Params.Query {
+		query[key] = cc.Params.Query.Get(key)
+	}
+	requested, skip, err := paging(query)
+	if err != nil {
+		return BadRequest(err.Error())
+	}
+	limit := s.Pagination.Limit(cc.Context, requested)
+	var offset int64
+	if skip != nil {
+		offset = *skip
+	}
+
+	pipeline, err := aggregation(query)
+	if err != nil {
+		return BadRequest(err.Error())
+	}
+	page, err := Typed[Document](cc.Handler).AggregatePage(pipeline, limit, offset)
+	if err != nil {
+		return Unexpected(err.Error())
+	}
+	return cc.Respond(fiber.StatusOK, page)
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func rankTotals(params map[string]string) (mongo.Pipeline, error) {
+	match := bson.M{}
+	if value, ok := params["min"]; ok {
+		min, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid params: min")
+		}
+		match["rank"] = bson.M{"$gte": min}
+	}
+	return mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{"_id": "$name", "total": bson.M{"$sum": "$rank"}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	}, nil
+}
+
+func TestAggregateController(t *testing.T) {
+	finds := 0
+	records := newService("records", record{}).
+		SetPagination(Pagination{Default: 2, Max: 3}).
+		SetHooks(Hooks{Before: HookChain{MethodFind: {func(hc *HookContext) error { finds++; return nil }}}}).
+		AddProtectedRoute(MethodFind, AggregateController, AggregatePath).
+		AddAggregation("ranks", rankTotals)
+	for i, name := range []string{"a", "a", "b", "c", "d", "e"} {
+		if _, err := Typed[record](records.Handler).Create(Document{"name": name, "rank": int64(i + 1)}, options.InsertOne()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	engine := serve(InitApp(), records)
+
+	groups := func(out interface{}) []string {
+		data, _ := object(t, out)["data"].([]interface{})
+		result := []string{}
+		for _, item := range data {
+			group := object(t, item)
+			result = append(result, group["_id"].(string)+"="+strconv.FormatFloat(group["total"].(float64), 'f', -1, 64))
+		}
+		return result
+	}
+	pages := map[string]struct {
+		groups []string
+		total  float64
+	}{
+		"/records/aggregate/ranks":                  {[]string{"a=3", "b=3"}, 5},
+		"/records/aggregate/ranks?$limit=10":        {[]string{"a=3", "b=3", "c=4"}, 5},
+		"/records/aggregate/ranks?$limit=2&$skip=3": {[]string{"d=5", "e=6"}, 5},
+		"/records/aggregate/ranks?min=4&$skip=1":    {[]string{"d=5", "e=6"}, 3},
+		"/records/aggregate/ranks?$skip=9":          {[]string{}, 5},
+	}
+	for path, expected := range pages {
+		status, _, out := send(t, engine, "GET", path, nil, "X-User", "user")
+		if status != fiber.StatusOK || !reflect.DeepEqual(groups(out), expected.groups) || object(t, out)["total"] != expected.total {
+			t.Errorf("%s returned %d %v", path, status, out)
+		}
+	}
+	if finds != len(pages) {
+		t.Errorf("find hooks ran %d times for %d aggregations", finds, len(pages))
+	}
+
+	failures := map[string]int{
+		"/records/aggregate/ranks?min=low":    fiber.StatusBadRequest,
+		"/records/aggregate/ranks?$limit=-1":  fiber.StatusBadRequest,
+		"/records/aggregate/ranks?$skip=many": fiber.StatusBadRequest,
+		"/records/aggregate/missing":          fiber.StatusNotFound,
+	}
+	for path, expected := range failures {
+		if status, _, out := send(t, engine, "GET", path, nil, "X-User", "user"); status != expected {
+			t.Errorf("%s returned %d %v", path, status, out)
+		}
+	}
+}
+
+func TestAggregateDocuments(t *testing.T) {
+	documents := []Document{
+		{"name": "a", "rank": int64(1), "score": 1.5},
+		{"name": "b", "rank": int64(2), "score": 2.5},
+		{"name": "a", "rank": int64(3)},
+	}
+	stages, err := ToStages(mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"rank": bson.M{"$gte": 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$name",
+			"count": bson.M{"$sum": 1},
+			"ranks": bson.M{"$push": "$rank"},
+			"max":   bson.M{"$max": "$rank"},
+			"score": bson.M{"$avg": "$score"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$project", Value: bson.M{"count": 1, "ranks": 1, "max": 1, "score": 1, "name": "$_id"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := AggregateDocuments(documents, stages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Document{
+		{"_id": "a", "name": "a", "count": int64(2), "ranks": bson.A{int64(1), int64(3)}, "max": int64(3), "score": 1.5},
+		{"_id": "b", "name": "b", "count": int64(1), "ranks": bson.A{int64(2)}, "max": int64(2), "score": 2.5},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("aggregated %v", results)
+	}
+
+	stages, _ = ToStages(mongo.Pipeline{{{Key: "$count", Value: "total"}}})
+	if results, err := AggregateDocuments(documents, stages); err != nil || !reflect.DeepEqual(results, []Document{{"total": int32(3)}}) {
+		t.Errorf("counted %v %v", results, err)
+	}
+	for _, stage := range []bson.D{{{Key: "$out", Value: "copy"}}, {{Key: "$limit", Value: -1}}, {{Key: "$group", Value: bson.M{"count": bson.M{"$sum": 1}}}}} {
+		stages, err := ToStages(mongo.Pipeline{stage})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := AggregateDocuments(documents, stages); err == nil {
+			t.Errorf("%v succeeded", stage)
+		}
+	}
+}
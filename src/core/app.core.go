@@ -1,22 +1,44 @@
 package core
 
 import (
+	"context"
 	"fmt"
+	"time"
 )
 
 type Services map[string]*Service
 type App struct {
 	Services Services
+	Hooks    Hooks
+	Events   *EventBus
+
+	// PublishTimeout bounds how long a write waits for full subscriptions.
+	PublishTimeout time.Duration
 }
 
 func InitApp() *App {
 	return &App{
-		Services: make(Services),
+		Services:       make(Services),
+		Events:         NewEventBus(EventBuffer),
+		PublishTimeout: EventTimeout,
 	}
 }
 
 func (a *App) InitServices(services Services) {
 	a.Services = services
+	for _, service := range services {
+		service.App = a
+	}
+}
+
+func (a *App) SetHooks(h Hooks) *App {
+	a.Hooks = h
+	return a
+}
+
+func (a *App) SetPublishTimeout(timeout time.Duration) *App {
+	a.PublishTimeout = timeout
+	return a
 }
 
 func (a *App) Service(name string) (*Service, error) {
@@ -26,3 +48,21 @@ func (a *App) Service(name string) (*Service, error) {
 	}
 	return service, nil
 }
+
+// Subscribe listens to the ServiceEvents of a type published by a service.
+func (a *App) Subscribe(service string, eventType string) (*Subscription, error) {
+	return a.Events.Subscribe(EventTopic(service, eventType))
+}
+
+func (a *App) Listening(service string, eventType string) bool {
+	return a.Events.HasSubscribers(EventTopic(service, eventType))
+}
+
+func (a *App) Publish(ctx context.Context, e ServiceEvent) error {
+	if a.PublishTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.PublishTimeout)
+		defer cancel()
+	}
+	return a.Events.Publish(ctx, EventTopic(e.Service, e.Type), e)
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	ArchivedField   = "archived"
+	ArchivedAtField = "archived_at"
+)
+
+var ErrSoftDeleteDisabled = errors.New("soft delete is not enabled")
+
+// SetSoftDelete makes Delete archive documents, hidden unless WithArchived.
+func (s *Service) SetSoftDelete() *Service {
+	s.SoftDelete = true
+	return s
+}
+
+func (s *Service) hidesArchived(archived bool) bool {
+	return s.SoftDelete && !archived
+}
+
+func active(filter interface{}) interface{} {
+	condition := bson.M{ArchivedField: bson.M{"$ne": true}}
+	if filter == nil {
+		return condition
+	}
+	return bson.M{"$and": primitive.A{filter, condition}}
+}
+
+func archivedOnly(filter interface{}) interface{} {
+	condition := bson.M{ArchivedField: true}
+	if filter == nil {
+		return condition
+	}
+	return bson.M{"$and": primitive.A{filter, condition}}
+}
+
+func activePipeline(pipeline interface{}) (mongo.Pipeline, error) {
+	stages, err := ToStages(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	scoped := mongo.Pipeline{{{Key: "$match", Value: active(nil)}}}
+	for _, stage := range stages {
+		scoped = append(scoped, bson.D{{Key: stage.Name, Value: stage.Value}})
+	}
+	return scoped, nil
+}
+
+func (s *Service) archiveUpdate() bson.M {
+	now := time.Now().UTC()
+	changes := bson.M{ArchivedField: true, ArchivedAtField: now}
+	if s.Timestamps {
+		changes[UpdatedAtField] = now
+	}
+	update := bson.M{"$set": changes}
+	if s.Versioning {
+		update["$inc"] = bson.M{VersionField: int64(1)}
+	}
+	return update
+}
+
+func (s *Service) restoreUpdate() bson.M {
+	changes := bson.M{ArchivedField: false}
+	if s.Timestamps {
+		changes[UpdatedAtField] = time.Now().UTC()
+	}
+	update := bson.M{
+		"$set":   changes,
+		"$unset": bson.M{ArchivedAtField: ""},
+	}
+	if s.Versioning {
+		update["$inc"] = bson.M{VersionField: int64(1)}
+	}
+	return update
+}
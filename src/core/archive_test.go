@@ -0,0 +1,106 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type archivable struct {
+	ID         primitive.ObjectID `json:"_id" bson:"_id" access:"readonly"`
+	Name       string             `json:"name" bson:"name"`
+	Archived   bool               `json:"archived" bson:"archived" access:"readonly"`
+	ArchivedAt *time.Time         `json:"archived_at,omitempty" bson:"archived_at,omitempty" access:"readonly"`
+}
+
+func TestSoftDelete(t *testing.T) {
+	items := newService("items", archivable{}).SetSoftDelete().SetResource(CRUD[archivable, archivable]{})
+	engine := serve(InitApp(), items)
+	ids := []string{}
+	for _, name := range []string{"a", "b"} {
+		item, err := Typed[archivable](items.Handler).Create(Document{"name": name}, options.InsertOne())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, item.ID.Hex())
+	}
+	a, b := "/items/"+ids[0], "/items/"+ids[1]
+	user := []string{"X-User", "user"}
+	admin := []string{"X-User", "admin", "X-Role", AdminRole}
+
+	status, _, out := send(t, engine, "DELETE", a, nil, user...)
+	if document := object(t, out); status != fiber.StatusOK || document["archived"] != true || document["archived_at"] == nil {
+		t.Fatalf("delete returned %d %v", status, out)
+	}
+	if status, _, out := send(t, engine, "DELETE", a, nil, user...); status != fiber.StatusNotFound {
+		t.Errorf("delete of an archived document returned %d %v", status, out)
+	}
+	if status, _, out := send(t, engine, "GET", a, nil, user...); status != fiber.StatusNotFound {
+		t.Errorf("get of an archived document returned %d %v", status, out)
+	}
+	if status, _, out := send(t, engine, "PATCH", a, map[string]interface{}{"name": "x"}, user...); status != fiber.StatusNotFound {
+		t.Errorf("patch of an archived document returned %d %v", status, out)
+	}
+	status, _, out = send(t, engine, "GET", "/items", nil, user...)
+	if page := object(t, out); status != fiber.StatusOK || page["total"] != float64(1) {
+		t.Errorf("find returned %d %v", status, out)
+	}
+
+	if status, _, out := send(t, engine, "GET", a+"?$archived=true", nil, user...); status != fiber.StatusForbidden {
+		t.Errorf("get of an archived document by a user returned %d %v", status, out)
+	}
+	if status, _, out := send(t, engine, "GET", a+"?$archived=true", nil, admin...); status != fiber.StatusOK || object(t, out)["archived"] != true {
+		t.Errorf("get of an archived document by an admin returned %d %v", status, out)
+	}
+	status, _, out = send(t, engine, "GET", "/items?$archived=true", nil, admin...)
+	if page := object(t, out); status != fiber.StatusOK || page["total"] != float64(2) {
+		t.Errorf("find of archived documents returned %d %v", status, out)
+	}
+
+	status, _, out = send(t, engine, "PATCH", a+"/restore", nil, admin...)
+	if document := object(t, out); status != fiber.StatusOK || document["archived"] != false || document["archived_at"] != nil {
+		t.Fatalf("restore returned %d %v", status, out)
+	}
+	if status, _, out := send(t, engine, "PATCH", a+"/restore", nil, admin...); status != fiber.StatusNotFound {
+		t.Errorf("restore of an active document returned %d %v", status, out)
+	}
+	if status, _, out := send(t, engine, "GET", a, nil, user...); status != fiber.StatusOK {
+		t.Errorf("get of a restored document returned %d %v", status, out)
+	}
+
+	if status, _, out := send(t, engine, "DELETE", b, nil, user...); status != fiber.StatusOK {
+		t.Fatalf("delete returned %d %v", status, out)
+	}
+	for _, path := range []string{a, b} {
+		if status, _, out := send(t, engine, "DELETE", path+"/purge", nil, admin...); status != fiber.StatusOK {
+			t.Errorf("purge of %s returned %d %v", path, status, out)
+		}
+	}
+	count, err := items.Entity.Adapter.CountDocuments(context.Background(), Document{}, nil)
+	if err != nil || count != 0 {
+		t.Errorf("stored %d documents after purging, %v", count, err)
+	}
+}
+
+func TestRestoreWithoutSoftDelete(t *testing.T) {
+	items := newService("items", archivable{}).SetResource(CRUD[archivable, archivable]{})
+	engine := serve(InitApp(), items)
+	item, err := Typed[archivable](items.Handler).Create(Document{"name": "a"}, options.InsertOne())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, _, out := send(t, engine, "PATCH", "/items/"+item.ID.Hex()+"/restore", nil, "X-User", "admin", "X-Role", AdminRole); status != fiber.StatusBadRequest {
+		t.Errorf("restore returned %d %v", status, out)
+	}
+	if status, _, out := send(t, engine, "DELETE", "/items/"+item.ID.Hex(), nil, "X-User", "user"); status != fiber.StatusOK {
+		t.Fatalf("delete returned %d %v", status, out)
+	}
+	count, err := items.Entity.Adapter.CountDocuments(context.Background(), Document{}, nil)
+	if err != nil || count != 0 {
+		t.Errorf("stored %d documents after a hard delete, %v", count, err)
+	}
+}
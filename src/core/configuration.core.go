@@ -13,6 +13,8 @@ type MailerConfig struct {
 }
 
 type DatabaseConfig struct {
+	ADAPTER  string
+	URL      string
 	HOST     string
 	PORT     string
 	USER     string
@@ -43,6 +45,8 @@ func Configuration() *Config {
 			port = 8080
 		}
 
+		db_adapter := os.Getenv("DATABASE_ADAPTER")
+		db_url := os.Getenv("DATABASE_URL")
 		db_port := os.Getenv("MONGODB_PORT")
 		db_host := os.Getenv("MONGODB_HOST")
 		db_user := os.Getenv("MONGODB_USER")
@@ -67,6 +71,8 @@ func Configuration() *Config {
 		instance = &Config{
 			PORT: port,
 			DATABASE: DatabaseConfig{
+				ADAPTER:  db_adapter,
+				URL:      db_url,
 				PORT:     db_port,
 				HOST:     db_host,
 				USER:     db_user,
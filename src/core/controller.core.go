@@ -0,0 +1,78 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Controller func(cc *ControllerContext) error
+
+// AdminRole is the role reading and writing the fields tagged admin.
+const AdminRole = "admin"
+
+// Principal is who a request acts for. Internal calls may act for no user.
+type Principal struct {
+	User     string
+	Role     string
+	Internal bool
+}
+
+func (p Principal) Admin() bool {
+	return p.Internal || p.Role == AdminRole
+}
+
+// ControllerContext is what a controller acts on. Ctx is nil for internal calls.
+type ControllerContext struct {
+	Ctx         *fiber.Ctx
+	Context     context.Context
+	Method      string
+	Handler     Handler
+	Service     *Service
+	Entity      Entity
+	App         *App
+	Principal   Principal
+	Data        interface{}
+	Params      Params
+	ContentType string
+
+	// Status and Result are the response recorded by Respond.
+	Status int
+	Result interface{}
+
+	rawQuery string
+	query    *Query
+	queryErr error
+}
+
+// Query parses the query string of the request once.
+func (cc *ControllerContext) Query() (Query, error) {
+	if cc.query == nil {
+		query, err := cc.Service.Query(cc.rawQuery)
+		cc.query, cc.queryErr = &query, err
+	}
+	return *cc.query, cc.queryErr
+}
+
+// BodyParser decodes the data of the request into out.
+func (cc *ControllerContext) BodyParser(out interface{}) error {
+	if cc.Data == nil {
+		if cc.Ctx != nil {
+			return cc.Ctx.BodyParser(out)
+		}
+		return errors.New("missing payload")
+	}
+	body, err := json.Marshal(cc.Data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, out)
+}
+
+// Respond records the response Bind writes once the after hooks ran.
+func (cc *ControllerContext) Respond(status int, v interface{}) error {
+	cc.Status, cc.Result = status, v
+	return nil
+}
@@ -0,0 +1,454 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Resource serves every CRUD route of a service, see Service.SetResource.
+type Resource interface {
+	Find(cc *ControllerContext) error
+	Get(cc *ControllerContext) error
+	Create(cc *ControllerContext) error
+	Patch(cc *ControllerContext) error
+	Update(cc *ControllerContext) error
+	Delete(cc *ControllerContext) error
+	PatchMany(cc *ControllerContext) error
+	DeleteMany(cc *ControllerContext) error
+	Restore(cc *ControllerContext) error
+	Purge(cc *ControllerContext) error
+}
+
+// CRUD is the default Resource of a service storing T and responding with R.
+type CRUD[T any, R any] struct {
+	// Owner filters the documents the principal acts on, nil for every document.
+	Owner func(cc *ControllerContext) interface{}
+
+	// Archived allows $archived, for admins only when nil.
+	Archived func(cc *ControllerContext) bool
+
+	// Prepare checks and completes the payloads of Create and Update.
+	Prepare func(cc *ControllerContext, payload Document) error
+
+	// Response maps the documents to responses. It may be nil when R is T.
+	Response func(*T) R
+
+	// Conflict is the message of a duplicate key error.
+	Conflict string
+}
+
+func (r CRUD[T, R]) failure(err error) error {
+	var serverError *ServerError
+	switch {
+	case errors.As(err, &serverError):
+		return serverError
+	case err == mongo.ErrNoDocuments:
+		return NotFound("document not found")
+	case errors.Is(err, ErrVersionMismatch):
+		return PreconditionFailed(err.Error())
+	case mongo.IsDuplicateKeyError(err):
+		message := r.Conflict
+		if message == "" {
+			message = "document already exists"
+		}
+		return Conflict(message)
+	case errors.Is(err, ErrMultiNotAllowed), errors.Is(err, ErrSoftDeleteDisabled), errors.Is(err, ErrInvalidCursor), errors.Is(err, ErrNullSortKey):
+		return BadRequest(err.Error())
+	}
+	return Unexpected(err.Error())
+}
+
+// Verify fails for a CRUD responding with R other than T and no Response.
+func (r CRUD[T, R]) Verify() error {
+	if _, same := any((*T)(nil)).(*R); !same && r.Response == nil {
+		return fmt.Errorf("crud of %T responding with %T has no Response", *new(T), *new(R))
+	}
+	return nil
+}
+
+func (r CRUD[T, R]) response(item *T) R {
+	if r.Response != nil {
+		return r.Response(item)
+	}
+	response, _ := any(*item).(R)
+	return response
+}
+
+func (r CRUD[T, R]) owned(cc *ControllerContext, filter bson.M) interface{} {
+	if r.Owner == nil {
+		return filter
+	}
+	owner := r.Owner(cc)
+	if owner == nil {
+		return filter
+	}
+	if len(filter) == 0 {
+		return owner
+	}
+	return bson.M{"$and": primitive.A{filter, owner}}
+}
+
+func (r CRUD[T, R]) byID(cc *ControllerContext) (interface{}, error) {
+	id := cc.Params.Route["id"]
+	if id == "" {
+		return nil, BadRequest("missing params: id")
+	}
+	return r.owned(cc, bson.M{"_id": idKey(id)}), nil
+}
+
+func idKey(id string) interface{} {
+	if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+		return oid
+	}
+	return id
+}
+
+func (r CRUD[T, R]) withArchived(cc *ControllerContext, h Handler, archived bool) (Handler, error) {
+	if !archived {
+		return h, nil
+	}
+	allowed := cc.Principal.Admin()
+	if r.Archived != nil {
+		allowed = r.Archived(cc)
+	}
+	if !allowed {
+		return h, Forbidden("archived documents are not allowed")
+	}
+	return h.WithArchived(), nil
+}
+
+func payload(cc *ControllerContext, data interface{}) (Document, error) {
+	if data == nil {
+		return nil, BadRequest("missing payload")
+	}
+	if cc.Principal.Internal {
+		document, err := ToDocument(data)
+		if err != nil {
+			return nil, BadRequest(err.Error())
+		}
+		return document, nil
+	}
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, BadRequest(err.Error())
+	}
+	document := Document{}
+	if err := bson.UnmarshalExtJSON(body, true, &document); err != nil {
+		return nil, BadRequest(err.Error())
+	}
+	return document, nil
+}
+
+func body(cc *ControllerContext) ([]byte, error) {
+	if cc.Data == nil {
+		return nil, nil
+	}
+	return json.Marshal(cc.Data)
+}
+
+func (r CRUD[T, R]) prepare(cc *ControllerContext, payload Document) error {
+	if r.Prepare == nil {
+		return nil
+	}
+	return r.Prepare(cc, payload)
+}
+
+func (r CRUD[T, R]) patch(cc *ControllerContext, current func() (Document, error)) (interface{}, error) {
+	if operators, ok := cc.Data.(Operators); ok {
+		return operators, nil
+	}
+	patch, err := body(cc)
+	if err != nil {
+		return nil, BadRequest(err.Error())
+	}
+	var operators Operators
+	switch {
+	case strings.HasPrefix(cc.ContentType, MergePatchType):
+		operators, err = MergePatch(patch)
+	case strings.HasPrefix(cc.ContentType, JSONPatchType):
+		if current == nil {
+			return nil, BadRequest("json patch needs a single document")
+		}
+		document, er := current()
+		if er != nil {
+			return nil, r.failure(er)
+		}
+		operators, err = JSONPatch(document, patch)
+	default:
+		return payload(cc, cc.Data)
+	}
+	if err != nil {
+		return nil, BadRequest(err.Error())
+	}
+	if len(operators) == 0 {
+		return nil, BadRequest("missing payload")
+	}
+	return operators, nil
+}
+
+func (r CRUD[T, R]) bulk(cc *ControllerContext, results Bulk[T], err error) error {
+	if err != nil {
+		return r.failure(err)
+	}
+	response := MapBulk(results, r.response)
+	for i := range response {
+		if response[i].Error != nil {
+			response[i].Error = r.failure(response[i].Error)
+		}
+	}
+	return cc.Respond(fiber.StatusMultiStatus, response)
+}
+
+func (r CRUD[T, R]) many(cc *ControllerContext) (Handler, interface{}, error) {
+	query, err := cc.Query()
+	if err != nil {
+		return cc.Handler, nil, BadRequest(err.Error())
+	}
+	h, err := r.withArchived(cc, cc.Handler, query.Archived)
+	if err != nil {
+		return h, nil, err
+	}
+	if len(query.Filter) == 0 {
+		return h, nil, BadRequest("missing query")
+	}
+	return h, r.owned(cc, query.Filter), nil
+}
+
+func (r CRUD[T, R]) Find(cc *ControllerContext) error {
+	query, err := cc.Query()
+	if err != nil {
+		return BadRequest(err.Error())
+	}
+	h, err := r.withArchived(cc, cc.Handler, query.Archived)
+	if err != nil {
+		return err
+	}
+	s := cc.Service
+	page, err := Typed[T](h).Paginate(r.owned(cc, query.Filter), s.FindOptions(cc.Context, query), s.PageOptions(query))
+	if err != nil {
+		return r.failure(err)
+	}
+	return cc.Respond(fiber.StatusOK, MapPage(page, r.response))
+}
+
+func (r CRUD[T, R]) Get(cc *ControllerContext) error {
+	filter, err := r.byID(cc)
+	if err != nil {
+		return err
+	}
+	archived := false
+	if value := cc.Params.Query.Get("$archived"); value != "" {
+		if archived, err = strconv.ParseBool(value); err != nil {
+			return BadRequest("$archived must be a boolean")
+		}
+	}
+	h, err := r.withArchived(cc, cc.Handler, archived)
+	if err != nil {
+		return err
+	}
+	item, err := Typed[T](h).Get(filter, options.FindOne())
+	if err != nil {
+		return r.failure(err)
+	}
+	return cc.Respond(fiber.StatusOK, r.response(&item))
+}
+
+// Create creates a document, or every document of an array payload.
+func (r CRUD[T, R]) Create(cc *ControllerContext) error {
+	if items, ok := cc.Data.([]interface{}); ok {
+		return r.createMany(cc, items)
+	}
+	document, err := payload(cc, cc.Data)
+	if err != nil {
+		return err
+	}
+	if err := r.prepare(cc, document); err != nil {
+		return err
+	}
+	item, err := Typed[T](cc.Handler).Create(document, options.InsertOne())
+	if err != nil {
+		return r.failure(err)
+	}
+	return cc.Respond(fiber.StatusCreated, r.response(&item))
+}
+
+func (r CRUD[T, R]) createMany(cc *ControllerContext, items []interface{}) error {
+	documents := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		document, err := payload(cc, item)
+		if err != nil {
+			return BadRequest("every item must be a document")
+		}
+		if err := r.prepare(cc, document); err != nil {
+			return err
+		}
+		documents = append(documents, document)
+	}
+	results, err := Typed[T](cc.Handler).CreateMany(documents, options.InsertMany())
+	return r.bulk(cc, results, err)
+}
+
+func (r CRUD[T, R]) Patch(cc *ControllerContext) error {
+	filter, err := r.byID(cc)
+	if err != nil {
+		return err
+	}
+	h, read := cc.Handler, false
+	payload, err := r.patch(cc, func() (Document, error) {
+		document, err := Typed[Document](cc.Handler).Get(filter, options.FindOne())
+		if err != nil {
+			return nil, err
+		}
+		h, err = r.read(cc, document)
+		read = err == nil
+		return document, err
+	})
+	if err != nil {
+		return err
+	}
+	item, err := Typed[T](h).Patch(filter, payload, options.FindOneAndUpdate())
+	if read && cc.Handler.scope.version == nil && errors.Is(err, ErrVersionMismatch) {
+		return Conflict("document changed while patching")
+	}
+	if err != nil {
+		return r.failure(err)
+	}
+	return cc.Respond(fiber.StatusOK, r.response(&item))
+}
+
+// read scopes a write to the version of the document it was computed from.
+func (r CRUD[T, R]) read(cc *ControllerContext, document Document) (Handler, error) {
+	if !cc.Service.Versioning {
+		return cc.Handler, BadRequest("json patch needs a versioned service")
+	}
+	version, ok := documentVersion(document)
+	if !ok {
+		return cc.Handler, Conflict("document has no version")
+	}
+	if expected := cc.Handler.scope.version; expected != nil && *expected != version {
+		return cc.Handler, PreconditionFailed(ErrVersionMismatch.Error())
+	}
+	return cc.Handler.IfVersion(version), nil
+}
+
+// Update replaces a document, keeping the fields the principal may not write.
+// Versioned services write at the version the kept fields were read at.
+func (r CRUD[T, R]) Update(cc *ControllerContext) error {
+	filter, err := r.byID(cc)
+	if err != nil {
+		return err
+	}
+	document, err := payload(cc, cc.Data)
+	if err != nil {
+		return err
+	}
+	if err := r.prepare(cc, document); err != nil {
+		return err
+	}
+	existing, err := Typed[Document](cc.Handler).Get(filter, options.FindOne())
+	if err == mongo.ErrNoDocuments && cc.Service.Upsert && cc.Handler.scope.version == nil {
+		return r.upsert(cc, filter, document)
+	}
+	if err != nil {
+		return r.failure(err)
+	}
+	h := cc.Handler
+	if cc.Service.Versioning {
+		if h, err = r.read(cc, existing); err != nil {
+			return err
+		}
+	}
+	for field, value := range existing {
+		if _, ok := document[field]; !ok && !cc.Service.Access.Writable(field, false, cc.Principal.Admin()) {
+			document[field] = value
+		}
+	}
+	item, err := Typed[T](h).Update(filter, document, options.FindOneAndReplace())
+	if cc.Service.Versioning && cc.Handler.scope.version == nil && errors.Is(err, ErrVersionMismatch) {
+		return Conflict("document changed while updating")
+	}
+	if err != nil {
+		return r.failure(err)
+	}
+	return cc.Respond(fiber.StatusOK, r.response(&item))
+}
+
+// upsert creates the document of a PUT to an id no document has.
+func (r CRUD[T, R]) upsert(cc *ControllerContext, filter interface{}, document Document) error {
+	document["_id"] = idKey(cc.Params.Route["id"])
+	item, err := Typed[T](cc.Handler).Update(filter, document, options.FindOneAndReplace().SetUpsert(true))
+	if err != nil {
+		return r.failure(err)
+	}
+	return cc.Respond(fiber.StatusCreated, r.response(&item))
+}
+
+func (r CRUD[T, R]) Delete(cc *ControllerContext) error {
+	filter, err := r.byID(cc)
+	if err != nil {
+		return err
+	}
+	item, err := Typed[T](cc.Handler).Delete(filter, options.FindOneAndDelete())
+	if err != nil {
+		return r.failure(err)
+	}
+	return cc.Respond(fiber.StatusOK, r.response(&item))
+}
+
+func (r CRUD[T, R]) PatchMany(cc *ControllerContext) error {
+	h, filter, err := r.many(cc)
+	if err != nil {
+		return err
+	}
+	payload, err := r.patch(cc, nil)
+	if err != nil {
+		return err
+	}
+	results, err := Typed[T](h).PatchMany(filter, payload, options.FindOneAndUpdate())
+	return r.bulk(cc, results, err)
+}
+
+func (r CRUD[T, R]) DeleteMany(cc *ControllerContext) error {
+	h, filter, err := r.many(cc)
+	if err != nil {
+		return err
+	}
+	results, err := Typed[T](h).DeleteMany(filter, options.FindOneAndDelete())
+	return r.bulk(cc, results, err)
+}
+
+func (r CRUD[T, R]) Restore(cc *ControllerContext) error {
+	filter, err := r.byID(cc)
+	if err != nil {
+		return err
+	}
+	item, err := Typed[T](cc.Handler).Restore(filter, options.FindOneAndUpdate())
+	if err == mongo.ErrNoDocuments {
+		return NotFound("archived document not found")
+	}
+	if err != nil {
+		return r.failure(err)
+	}
+	return cc.Respond(fiber.StatusOK, r.response(&item))
+}
+
+func (r CRUD[T, R]) Purge(cc *ControllerContext) error {
+	filter, err := r.byID(cc)
+	if err != nil {
+		return err
+	}
+	item, err := Typed[T](cc.Handler).Purge(filter, options.FindOneAndDelete())
+	if err != nil {
+		return r.failure(err)
+	}
+	return cc.Respond(fiber.StatusOK, r.response(&item))
+}
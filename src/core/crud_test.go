@@ -0,0 +1,81 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestUpdateUpsert(t *testing.T) {
+	id := primitive.NewObjectID().Hex()
+	records := newService("records", record{}).SetResource(CRUD[record, record]{})
+	engine := serve(InitApp(), records)
+	if status, _, out := send(t, engine, "PUT", "/records/"+id, map[string]interface{}{"name": "a"}, "X-User", "user"); status != fiber.StatusNotFound {
+		t.Fatalf("update of a missing document returned %d %v", status, out)
+	}
+
+	records = newService("records", record{}).SetUpsert().SetTimestamps().SetVersioning().SetResource(CRUD[record, record]{})
+	engine = serve(InitApp(), records)
+	if status, _, out := send(t, engine, "PUT", "/records/"+id, map[string]interface{}{"name": "a"}, "X-User", "user", "If-Match", `"1"`); status != fiber.StatusNotFound {
+		t.Fatalf("conditional upsert of a missing document returned %d %v", status, out)
+	}
+	status, _, out := send(t, engine, "PUT", "/records/"+id, map[string]interface{}{"name": "a", "rank": 1}, "X-User", "user")
+	if document := object(t, out); status != fiber.StatusCreated || document["_id"] != id || document["name"] != "a" {
+		t.Fatalf("upsert returned %d %v", status, out)
+	}
+	status, _, out = send(t, engine, "PUT", "/records/"+id, map[string]interface{}{"name": "b"}, "X-User", "user")
+	if document := object(t, out); status != fiber.StatusOK || document["name"] != "b" || document["rank"] != float64(0) {
+		t.Fatalf("update of an upserted document returned %d %v", status, out)
+	}
+	stored, err := Typed[Document](records.Handler).Get(Document{}, options.FindOne())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored[VersionField] != int64(2) || stored[CreatedAtField] == nil {
+		t.Errorf("stored %v", stored)
+	}
+}
+
+func TestUpdateKeepsProtectedFields(t *testing.T) {
+	accounts, adapter, engine, id := newAccounts(t)
+
+	status, _, out := send(t, engine, "PUT", "/accounts/"+id, map[string]interface{}{"name": "Grace", "tags": []string{"a"}}, "X-User", "user")
+	document := object(t, out)
+	if status != fiber.StatusOK || document["name"] != "Grace" || document["email"] != "ada@example.com" || document["version"] != float64(2) {
+		t.Fatalf("update returned %d %v", status, out)
+	}
+	for _, field := range []string{"secret", "role"} {
+		if _, ok := document[field]; ok {
+			t.Errorf("update returned the field %s", field)
+		}
+	}
+	if status, _, out := send(t, engine, "PUT", "/accounts/"+id, map[string]interface{}{"name": "Grace", "role": "admin"}, "X-User", "user"); status != fiber.StatusForbidden {
+		t.Errorf("update of an admin field returned %d %v", status, out)
+	}
+	stored, err := Typed[account](accounts.Handler).Get(Document{}, options.FindOne())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Role != "user" || stored.Email != "ada@example.com" || stored.Version != 2 {
+		t.Errorf("update dropped the protected fields %+v", stored)
+	}
+
+	adapter.race = func() {
+		oid, _ := primitive.ObjectIDFromHex(id)
+		if _, err := Typed[account](accounts.Handler).Patch(Document{"_id": oid}, Document{"role": "admin"}, options.FindOneAndUpdate()); err != nil {
+			t.Error(err)
+		}
+	}
+	if status, _, out := send(t, engine, "PUT", "/accounts/"+id, map[string]interface{}{"name": "Ken"}, "X-User", "user"); status != fiber.StatusConflict {
+		t.Fatalf("update of a concurrently changed document returned %d %v", status, out)
+	}
+	stored, err = Typed[account](accounts.Handler).Get(Document{}, options.FindOne())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != "Grace" || stored.Role != "admin" || stored.Version != 3 {
+		t.Errorf("stored %+v", stored)
+	}
+}
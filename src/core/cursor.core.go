@@ -0,0 +1,337 @@
+package core
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	DefaultLimit int64 = 25
+	MaxLimit     int64 = 100
+)
+
+// Pagination bounds the page size, DefaultLimit and MaxLimit when zero.
+type Pagination struct {
+	Default int64
+	Max     int64
+}
+
+type paginationKey struct{}
+
+// WithoutPagination lifts the page size bounds of internal calls.
+func WithoutPagination(ctx context.Context) context.Context {
+	return context.WithValue(ctx, paginationKey{}, true)
+}
+
+func paginationDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(paginationKey{}).(bool)
+	return disabled
+}
+
+// Limit returns the effective limit, zero meaning no limit.
+func (p Pagination) Limit(ctx context.Context, requested *int64) int64 {
+	if ctx != nil && paginationDisabled(ctx) {
+		if requested == nil {
+			return 0
+		}
+		return *requested
+	}
+	defaultLimit, max := p.Default, p.Max
+	if defaultLimit <= 0 {
+		defaultLimit = DefaultLimit
+	}
+	if max <= 0 {
+		max = MaxLimit
+	}
+	if defaultLimit > max {
+		defaultLimit = max
+	}
+	if requested == nil || *requested <= 0 {
+		return defaultLimit
+	}
+	if *requested > max {
+		return max
+	}
+	return *requested
+}
+
+// PageMode selects skip or keyset cursor paging.
+type PageMode string
+
+const (
+	PageSkip   PageMode = "skip"
+	PageCursor PageMode = "cursor"
+)
+
+// CountMode selects how page totals are computed. Estimated ones ignore filters.
+type CountMode string
+
+const (
+	CountExact     CountMode = "exact"
+	CountNone      CountMode = "none"
+	CountEstimated CountMode = "estimated"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrNullSortKey   = errors.New("cursor pages cannot sort on null values")
+)
+
+type PageOptions struct {
+	Mode   PageMode
+	Count  CountMode
+	Cursor string
+}
+
+type pageCursor struct {
+	Keys   []string    `bson:"k"`
+	Values primitive.A `bson:"v"`
+	Prev   bool        `bson:"p,omitempty"`
+}
+
+// encodeCursor signs cursors so that clients cannot forge keyset values.
+func encodeCursor(cursor pageCursor) (string, error) {
+	data, err := bson.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data) + "." + base64.RawURLEncoding.EncodeToString(signCursor(data)), nil
+}
+
+// signCursor keys its MAC with a cursor-only key derived from the JWT secret.
+func signCursor(data []byte) []byte {
+	key := hmac.New(sha256.New, []byte(Configuration().JWT_SECRET))
+	key.Write([]byte("cursor"))
+	mac := hmac.New(sha256.New, key.Sum(nil))
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func decodeCursor(token string, order bson.D) (pageCursor, error) {
+	cursor := pageCursor{}
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return cursor, ErrInvalidCursor
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, signCursor(data)) {
+		return cursor, ErrInvalidCursor
+	}
+	if err := bson.Unmarshal(data, &cursor); err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if len(cursor.Keys) != len(order) || len(cursor.Values) != len(order) {
+		return cursor, fmt.Errorf("%w: the sort does not match", ErrInvalidCursor)
+	}
+	for i, e := range order {
+		if cursor.Keys[i] != e.Key {
+			return cursor, fmt.Errorf("%w: the sort does not match", ErrInvalidCursor)
+		}
+	}
+	return cursor, nil
+}
+
+func sortDirection(v interface{}) int {
+	if n, ok := toFloat(v); ok && n < 0 {
+		return -1
+	}
+	return 1
+}
+
+// cursorOrder appends _id to the sort to make the order stable.
+func cursorOrder(sort interface{}) (bson.D, error) {
+	order, err := toOrderedDocument(sort)
+	if err != nil {
+		return nil, err
+	}
+	direction := 1
+	for _, e := range order {
+		if e.Key == "_id" {
+			return order, nil
+		}
+		direction = sortDirection(e.Value)
+	}
+	return append(order, bson.E{Key: "_id", Value: direction}), nil
+}
+
+func reverseOrder(order bson.D) bson.D {
+	reversed := make(bson.D, 0, len(order))
+	for _, e := range order {
+		reversed = append(reversed, bson.E{Key: e.Key, Value: -sortDirection(e.Value)})
+	}
+	return reversed
+}
+
+func keyset(order bson.D, cursor pageCursor) bson.M {
+	clauses := primitive.A{}
+	for i, e := range order {
+		clause := bson.M{}
+		for j := 0; j < i; j++ {
+			clause[order[j].Key] = cursor.Values[j]
+		}
+		operator := "$gt"
+		if (sortDirection(e.Value) < 0) != cursor.Prev {
+			operator = "$lt"
+		}
+		clause[e.Key] = bson.M{operator: cursor.Values[i]}
+		clauses = append(clauses, clause)
+	}
+	return bson.M{"$or": clauses}
+}
+
+// withKeys adds the sort keys to inclusion projections to build cursors from.
+func withKeys(projection interface{}, order bson.D) (interface{}, error) {
+	if projection == nil {
+		return nil, nil
+	}
+	document, err := ToDocument(projection)
+	if err != nil {
+		return nil, err
+	}
+	include := false
+	for _, value := range document {
+		if truthy(value) {
+			include = true
+		}
+	}
+	if !include {
+		return document, nil
+	}
+	for _, e := range order {
+		document[e.Key] = 1
+	}
+	return document, nil
+}
+
+func cursorAt(document Document, order bson.D, prev bool) (string, error) {
+	cursor := pageCursor{Prev: prev}
+	for _, e := range order {
+		value, _ := lookupField(document, e.Key)
+		if value == nil {
+			return "", fmt.Errorf("%w: %s", ErrNullSortKey, e.Key)
+		}
+		cursor.Keys = append(cursor.Keys, e.Key)
+		cursor.Values = append(cursor.Values, value)
+	}
+	return encodeCursor(cursor)
+}
+
+// CursorPage finds the page of the cursor of pageOptions, or the first page.
+func (t TypedHandler[T]) CursorPage(customFilter interface{}, customOptions FindHandlerOptions, pageOptions PageOptions) (Page[T], error) {
+	findOptions := options.MergeFindOptions(customOptions)
+	order, err := cursorOrder(findOptions.Sort)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	projection, err := withKeys(findOptions.Projection, order)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	findOptions.Projection = projection
+	findOptions.Skip = nil
+
+	cursor := pageCursor{}
+	filter := customFilter
+	if pageOptions.Cursor != "" {
+		cursor, err = decodeCursor(pageOptions.Cursor, order)
+		if err != nil {
+			return Page[T]{}, err
+		}
+		if filter == nil {
+			filter = keyset(order, cursor)
+		} else {
+			filter = bson.M{"$and": primitive.A{filter, keyset(order, cursor)}}
+		}
+	}
+	findOptions.SetSort(order)
+	if cursor.Prev {
+		findOptions.SetSort(reverseOrder(order))
+	}
+	var limit int64
+	if findOptions.Limit != nil && *findOptions.Limit > 0 {
+		limit = *findOptions.Limit
+		findOptions.SetLimit(limit + 1)
+	}
+
+	response := t.Handler.Find(filter, findOptions)
+	if response.Exception != nil {
+		return Page[T]{}, response.Exception
+	}
+	ctx := t.Handler.Context()
+	defer response.Result.Close(ctx)
+	documents := []Document{}
+	data := []T{}
+	for response.Result.Next(ctx) {
+		document := Document{}
+		if err := response.Result.Decode(&document); err != nil {
+			return Page[T]{}, err
+		}
+		var item T
+		if err := response.Result.Decode(&item); err != nil {
+			return Page[T]{}, err
+		}
+		documents = append(documents, document)
+		data = append(data, item)
+	}
+	if err := response.Result.Err(); err != nil {
+		return Page[T]{}, err
+	}
+
+	more := limit > 0 && int64(len(data)) > limit
+	if more {
+		documents, data = documents[:limit], data[:limit]
+	}
+	if cursor.Prev {
+		for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+			documents[i], documents[j] = documents[j], documents[i]
+			data[i], data[j] = data[j], data[i]
+		}
+	}
+
+	page := Page[T]{Data: data, Limit: limit}
+	if len(documents) > 0 {
+		if cursor.Prev || more {
+			if page.Next, err = cursorAt(documents[len(documents)-1], order, false); err != nil {
+				return Page[T]{}, err
+			}
+		}
+		if (!cursor.Prev && pageOptions.Cursor != "") || (cursor.Prev && more) {
+			if page.Prev, err = cursorAt(documents[0], order, true); err != nil {
+				return Page[T]{}, err
+			}
+		}
+	}
+	if err := t.count(&page, customFilter, pageOptions.Count); err != nil {
+		return Page[T]{}, err
+	}
+	return page, nil
+}
+
+func cursorSortable(sort bson.D, schema interface{}) error {
+	nullable := map[string]bool{}
+	for _, column := range schemaColumns(schema) {
+		nullable[column.Name] = column.Nullable
+	}
+	for _, e := range sort {
+		if e.Key == "_id" {
+			continue
+		}
+		if null, ok := nullable[e.Key]; !ok || null {
+			return fmt.Errorf("%w: %s", ErrNullSortKey, e.Key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package core
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func withSecret(t *testing.T, secret string) {
+	t.Helper()
+	previous := instance
+	instance = &Config{JWT_SECRET: secret}
+	t.Cleanup(func() { instance = previous })
+}
+
+func ranks(t *testing.T, page map[string]interface{}) []float64 {
+	t.Helper()
+	data, _ := page["data"].([]interface{})
+	result := []float64{}
+	for _, item := range data {
+		rank, _ := object(t, item)["rank"].(float64)
+		result = append(result, rank)
+	}
+	return result
+}
+
+func equalRanks(a []float64, b ...float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func newCursorRecords(t *testing.T) *fiber.App {
+	t.Helper()
+	withSecret(t, "secret")
+	records := newService("records", record{}).
+		SetPageMode(PageCursor).
+		SetSortable("rank").
+		SetResource(CRUD[record, record]{})
+	for rank := 1; rank <= 5; rank++ {
+		if _, err := Typed[record](records.Handler).Create(Document{"name": "record", "rank": int64(rank)}, options.InsertOne()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return serve(InitApp(), records)
+}
+
+func cursorPage(t *testing.T, engine *fiber.App, cursor string) (int, map[string]interface{}) {
+	t.Helper()
+	path := "/records?$sort[rank]=1&$limit=2"
+	if cursor != "" {
+		path += "&$cursor=" + url.QueryEscape(cursor)
+	}
+	status, _, out := send(t, engine, "GET", path, nil, "X-User", "user")
+	page, _ := out.(map[string]interface{})
+	return status, page
+}
+
+func TestCursorPages(t *testing.T) {
+	engine := newCursorRecords(t)
+
+	status, first := cursorPage(t, engine, "")
+	if status != fiber.StatusOK || !equalRanks(ranks(t, first), 1, 2) || first["prev"] != nil {
+		t.Fatalf("first page returned %d %v", status, first)
+	}
+	status, second := cursorPage(t, engine, first["next"].(string))
+	if status != fiber.StatusOK || !equalRanks(ranks(t, second), 3, 4) || second["prev"] == nil {
+		t.Fatalf("second page returned %d %v", status, second)
+	}
+	status, last := cursorPage(t, engine, second["next"].(string))
+	if status != fiber.StatusOK || !equalRanks(ranks(t, last), 5) || last["next"] != nil {
+		t.Fatalf("last page returned %d %v", status, last)
+	}
+	status, back := cursorPage(t, engine, second["prev"].(string))
+	if status != fiber.StatusOK || !equalRanks(ranks(t, back), 1, 2) {
+		t.Fatalf("previous page returned %d %v", status, back)
+	}
+}
+
+func TestCursorForgery(t *testing.T) {
+	engine := newCursorRecords(t)
+	_, first := cursorPage(t, engine, "")
+	next, _ := first["next"].(string)
+	tampered := []byte(next)
+	if tampered[0] == 'A' {
+		tampered[0] = 'B'
+	} else {
+		tampered[0] = 'A'
+	}
+
+	data, err := bson.Marshal(pageCursor{Keys: []string{"rank", "_id"}, Values: bson.A{int64(0), ""}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(data)
+	jwtSigned := base64.RawURLEncoding.EncodeToString(data) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	unsigned := base64.RawURLEncoding.EncodeToString(data)
+
+	forged := map[string]string{
+		"garbage":          "garbage",
+		"unsigned":         unsigned,
+		"tampered":         string(tampered),
+		"jwt secret":       jwtSigned,
+		"other sort order": mustCursor(t, pageCursor{Keys: []string{"name", "_id"}, Values: bson.A{"a", ""}}),
+	}
+	for name, cursor := range forged {
+		if status, out := cursorPage(t, engine, cursor); status != fiber.StatusBadRequest {
+			t.Errorf("%s cursor returned %d %v", name, status, out)
+		}
+	}
+}
+
+func mustCursor(t *testing.T, cursor pageCursor) string {
+	t.Helper()
+	token, err := encodeCursor(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestCursorNullableSort(t *testing.T) {
+	withSecret(t, "secret")
+	accounts := newService("accounts", account{}).
+		SetPageMode(PageCursor).
+		SetSortable("_id", "name", "tags").
+		SetResource(CRUD[account, account]{})
+	engine := serve(InitApp(), accounts)
+
+	if status, _, out := send(t, engine, "GET", "/accounts?$sort[tags]=1", nil, "X-User", "user"); status != fiber.StatusBadRequest {
+		t.Errorf("cursor page sorted on a nullable field returned %d %v", status, out)
+	}
+	if status, _, out := send(t, engine, "GET", "/accounts?$sort[name]=1&$sort[_id]=-1", nil, "X-User", "user"); status != fiber.StatusOK {
+		t.Errorf("cursor page sorted on a required field returned %d %v", status, out)
+	}
+
+	order := bson.D{{Key: "rank", Value: 1}, {Key: "_id", Value: 1}}
+	if _, err := cursorAt(Document{"_id": "a"}, order, false); !errors.Is(err, ErrNullSortKey) {
+		t.Errorf("cursor of a document without a sort value returned %v", err)
+	}
+}
+
+func TestPaginationLimit(t *testing.T) {
+	n := func(v int64) *int64 { return &v }
+	ctx := context.Background()
+	cases := []struct {
+		pagination Pagination
+		requested  *int64
+		limit      int64
+	}{
+		{Pagination{}, nil, DefaultLimit},
+		{Pagination{}, n(MaxLimit + 1), MaxLimit},
+		{Pagination{Default: 5, Max: 10}, nil, 5},
+		{Pagination{Default: 5, Max: 10}, n(0), 5},
+		{Pagination{Default: 5, Max: 10}, n(7), 7},
+		{Pagination{Default: 5, Max: 10}, n(1000), 10},
+		{Pagination{Default: 50, Max: 10}, nil, 10},
+	}
+	for _, c := range cases {
+		if limit := c.pagination.Limit(ctx, c.requested); limit != c.limit {
+			t.Errorf("%+v limited %v to %d", c.pagination, c.requested, limit)
+		}
+	}
+	if limit := (Pagination{Max: 10}).Limit(WithoutPagination(ctx), nil); limit != 0 {
+		t.Errorf("unpaginated limit is %d", limit)
+	}
+	if limit := (Pagination{Max: 10}).Limit(WithoutPagination(ctx), n(1000)); limit != 1000 {
+		t.Errorf("unpaginated requested limit is %d", limit)
+	}
+}
+
+func TestFindPagination(t *testing.T) {
+	records := newService("records", record{}).
+		SetPagination(Pagination{Default: 2, Max: 3}).
+		SetResource(CRUD[record, record]{})
+	for i := 0; i < 5; i++ {
+		if _, err := Typed[record](records.Handler).Create(Document{"name": "record"}, options.InsertOne()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	engine := serve(InitApp(), records)
+
+	for path, size := range map[string]int{"/records": 2, "/records?$limit=10": 3, "/records?$limit=3&$skip=4": 1} {
+		status, _, out := send(t, engine, "GET", path, nil, "X-User", "user")
+		page := object(t, out)
+		data, _ := page["data"].([]interface{})
+		if status != fiber.StatusOK || len(data) != size || page["total"] != float64(5) {
+			t.Errorf("%s returned %d %v", path, status, out)
+		}
+	}
+
+	page, err := Calls[record](records).Find(context.Background(), Params{})
+	if err != nil || len(page.Data) != 2 {
+		t.Errorf("internal find returned %d records, %v", len(page.Data), err)
+	}
+	page, err = Calls[record](records).Find(WithoutPagination(context.Background()), Params{})
+	if err != nil || len(page.Data) != 5 || page.Limit != 0 {
+		t.Errorf("unpaginated internal find returned %d records, %v", len(page.Data), err)
+	}
+}
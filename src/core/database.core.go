@@ -32,3 +32,30 @@ func InitDatabase() *Database {
 	database = client.Database(config.DATABASE.NAME)
 	return database
 }
+
+type Storage interface {
+	Collection(name string, schema interface{}) Adapter
+}
+
+type MongoStorage struct {
+	Database *Database
+}
+
+func (m MongoStorage) Collection(name string, schema interface{}) Adapter {
+	return NewMongoAdapter(m.Database.Collection(name))
+}
+
+func InitStorage() Storage {
+	config := Configuration()
+	switch config.DATABASE.ADAPTER {
+	case "memory":
+		return NewMemoryStorage()
+	case SQLite, Postgres:
+		return NewSQLStorage(InitSQL(config.DATABASE.ADAPTER), config.DATABASE.ADAPTER)
+	case "", "mongo":
+		return MongoStorage{Database: InitDatabase()}
+	default:
+		log.Fatalf("unsupported database adapter %s", config.DATABASE.ADAPTER)
+	}
+	return nil
+}
@@ -0,0 +1,564 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Document = bson.M
+
+func ToDocument(v interface{}) (Document, error) {
+	document := Document{}
+	if v == nil {
+		return document, nil
+	}
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	err = bson.Unmarshal(data, &document)
+	return document, err
+}
+
+func toOrderedDocument(v interface{}) (bson.D, error) {
+	document := bson.D{}
+	if v == nil {
+		return document, nil
+	}
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	err = bson.Unmarshal(data, &document)
+	return document, err
+}
+
+func lookupField(document Document, path string) (interface{}, bool) {
+	var current interface{} = document
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case Document:
+			value, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func setField(document Document, path string, value interface{}) {
+	keys := strings.Split(path, ".")
+	current := document
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := current[key].(Document)
+		if !ok {
+			next = Document{}
+			current[key] = next
+		}
+		current = next
+	}
+	current[keys[len(keys)-1]] = value
+}
+
+func unsetField(document Document, path string) {
+	keys := strings.Split(path, ".")
+	current := document
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := current[key].(Document)
+		if !ok {
+			return
+		}
+		current = next
+	}
+	delete(current, keys[len(keys)-1])
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func compareValues(a interface{}, b interface{}) (int, bool) {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	switch x := a.(type) {
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	case bool:
+		y, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x == y:
+			return 0, true
+		case !x:
+			return -1, true
+		}
+		return 1, true
+	case primitive.DateTime:
+		y, ok := b.(primitive.DateTime)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	case primitive.ObjectID:
+		y, ok := b.(primitive.ObjectID)
+		if !ok {
+			return 0, false
+		}
+		return bytes.Compare(x[:], y[:]), true
+	case nil:
+		if b == nil {
+			return 0, true
+		}
+		return 0, false
+	}
+	return 0, false
+}
+
+func valuesEqual(a interface{}, b interface{}) bool {
+	if order, ok := compareValues(a, b); ok {
+		return order == 0
+	}
+	x, err := bson.Marshal(bson.D{{Key: "v", Value: canonical(a)}})
+	if err != nil {
+		return false
+	}
+	y, err := bson.Marshal(bson.D{{Key: "v", Value: canonical(b)}})
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(x, y)
+}
+
+func matchesValue(value interface{}, found bool, target interface{}) bool {
+	if !found {
+		return target == nil
+	}
+	if valuesEqual(value, target) {
+		return true
+	}
+	if items, ok := value.(primitive.A); ok {
+		for _, item := range items {
+			if valuesEqual(item, target) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchesOrder(value interface{}, found bool, target interface{}, accept func(int) bool) bool {
+	if !found {
+		return false
+	}
+	candidates := []interface{}{value}
+	if items, ok := value.(primitive.A); ok {
+		candidates = items
+	}
+	for _, candidate := range candidates {
+		if order, ok := compareValues(candidate, target); ok && accept(order) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesOperators(value interface{}, found bool, operators Document) (bool, error) {
+	for operator, target := range operators {
+		var matched bool
+		switch operator {
+		case "$eq":
+			matched = matchesValue(value, found, target)
+		case "$ne":
+			matched = !matchesValue(value, found, target)
+		case "$gt":
+			matched = matchesOrder(value, found, target, func(o int) bool { return o > 0 })
+		case "$gte":
+			matched = matchesOrder(value, found, target, func(o int) bool { return o >= 0 })
+		case "$lt":
+			matched = matchesOrder(value, found, target, func(o int) bool { return o < 0 })
+		case "$lte":
+			matched = matchesOrder(value, found, target, func(o int) bool { return o <= 0 })
+		case "$in", "$nin":
+			items, ok := target.(primitive.A)
+			if !ok {
+				return false, fmt.Errorf("%s needs an array", operator)
+			}
+			for _, item := range items {
+				if matchesValue(value, found, item) {
+					matched = true
+					break
+				}
+			}
+			if operator == "$nin" {
+				matched = !matched
+			}
+		case "$exists":
+			exists, ok := target.(bool)
+			if !ok {
+				return false, fmt.Errorf("$exists needs a boolean")
+			}
+			matched = found == exists
+		case "$regex":
+			pattern, ok := target.(string)
+			if !ok {
+				if regex, ok := target.(primitive.Regex); ok {
+					pattern = "(?" + regex.Options + ")" + regex.Pattern
+				} else {
+					return false, fmt.Errorf("$regex needs a string")
+				}
+			}
+			if flags, ok := operators["$options"].(string); ok && flags != "" {
+				pattern = "(?" + flags + ")" + pattern
+			}
+			expression, err := regexp.Compile(pattern)
+			if err != nil {
+				return false, err
+			}
+			text, ok := value.(string)
+			matched = found && ok && expression.MatchString(text)
+		case "$options":
+			matched = true
+		case "$not":
+			nested, ok := target.(Document)
+			if !ok {
+				return false, fmt.Errorf("$not needs a document")
+			}
+			result, err := matchesOperators(value, found, nested)
+			if err != nil {
+				return false, err
+			}
+			matched = !result
+		default:
+			return false, fmt.Errorf("unsupported operator %s", operator)
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func isOperatorDocument(v interface{}) (Document, bool) {
+	document, ok := v.(Document)
+	if !ok || len(document) == 0 {
+		return nil, false
+	}
+	for key := range document {
+		if !strings.HasPrefix(key, "$") {
+			return nil, false
+		}
+	}
+	return document, true
+}
+
+// MatchDocument evaluates a mongo filter against a normalized document.
+func MatchDocument(document Document, filter Document) (bool, error) {
+	for key, condition := range filter {
+		switch key {
+		case "$and", "$or", "$nor":
+			clauses, ok := condition.(primitive.A)
+			if !ok {
+				return false, fmt.Errorf("%s needs an array", key)
+			}
+			matches := 0
+			for _, clause := range clauses {
+				nested, ok := clause.(Document)
+				if !ok {
+					return false, fmt.Errorf("%s needs an array of documents", key)
+				}
+				matched, err := MatchDocument(document, nested)
+				if err != nil {
+					return false, err
+				}
+				if matched {
+					matches++
+				}
+			}
+			if key == "$and" && matches != len(clauses) {
+				return false, nil
+			}
+			if key == "$or" && matches == 0 {
+				return false, nil
+			}
+			if key == "$nor" && matches != 0 {
+				return false, nil
+			}
+			continue
+		}
+		if strings.HasPrefix(key, "$") {
+			return false, fmt.Errorf("unsupported operator %s", key)
+		}
+		value, found := lookupField(document, key)
+		if operators, ok := isOperatorDocument(condition); ok {
+			matched, err := matchesOperators(value, found, operators)
+			if err != nil || !matched {
+				return false, err
+			}
+			continue
+		}
+		if !matchesValue(value, found, condition) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// UpdateDocument applies a mongo update to a normalized document in place.
+func UpdateDocument(document Document, update Document) error {
+	for operator, fields := range update {
+		values, ok := fields.(Document)
+		if !ok {
+			return fmt.Errorf("%s needs a document", operator)
+		}
+		for path, value := range values {
+			if path == "_id" {
+				if current, found := document["_id"]; found && !valuesEqual(current, value) {
+					return fmt.Errorf("the immutable field '_id' cannot be modified")
+				}
+				continue
+			}
+			switch operator {
+			case "$set":
+				setField(document, path, value)
+			case "$setOnInsert":
+			case "$unset":
+				unsetField(document, path)
+			case "$inc":
+				amount, ok := toFloat(value)
+				if !ok {
+					return fmt.Errorf("cannot increment with non-numeric argument")
+				}
+				current, found := lookupField(document, path)
+				if !found {
+					setField(document, path, value)
+					continue
+				}
+				switch n := current.(type) {
+				case int32:
+					setField(document, path, n+int32(amount))
+				case int64:
+					setField(document, path, n+int64(amount))
+				case float64:
+					setField(document, path, n+amount)
+				default:
+					return fmt.Errorf("cannot apply $inc to a value of non-numeric type")
+				}
+			case "$mul":
+				factor, ok := toFloat(value)
+				if !ok {
+					return fmt.Errorf("cannot multiply with non-numeric argument")
+				}
+				current, found := lookupField(document, path)
+				if !found {
+					current = int32(0)
+				}
+				switch n := current.(type) {
+				case int32:
+					setField(document, path, int32(float64(n)*factor))
+				case int64:
+					setField(document, path, int64(float64(n)*factor))
+				case float64:
+					setField(document, path, n*factor)
+				default:
+					return fmt.Errorf("cannot apply $mul to a value of non-numeric type")
+				}
+			case "$min", "$max":
+				current, found := lookupField(document, path)
+				if !found {
+					setField(document, path, value)
+					continue
+				}
+				order, ok := compareValues(value, current)
+				if ok && ((operator == "$min" && order < 0) || (operator == "$max" && order > 0)) {
+					setField(document, path, value)
+				}
+			case "$push", "$addToSet":
+				items, err := arrayField(document, path, operator)
+				if err != nil {
+					return err
+				}
+				for _, item := range eachItems(value) {
+					if operator == "$addToSet" && containsValue(items, item) {
+						continue
+					}
+					items = append(items, item)
+				}
+				setField(document, path, items)
+			case "$pull":
+				items, err := arrayField(document, path, operator)
+				if err != nil {
+					return err
+				}
+				kept := primitive.A{}
+				for _, item := range items {
+					matched, err := matchesPull(item, value)
+					if err != nil {
+						return err
+					}
+					if !matched {
+						kept = append(kept, item)
+					}
+				}
+				if _, found := lookupField(document, path); found {
+					setField(document, path, kept)
+				}
+			default:
+				return fmt.Errorf("unsupported update operator %s", operator)
+			}
+		}
+	}
+	return nil
+}
+
+func arrayField(document Document, path string, operator string) (primitive.A, error) {
+	current, found := lookupField(document, path)
+	if !found {
+		return primitive.A{}, nil
+	}
+	items, ok := current.(primitive.A)
+	if !ok {
+		return nil, fmt.Errorf("cannot apply %s to a non-array field", operator)
+	}
+	return append(primitive.A{}, items...), nil
+}
+
+func eachItems(value interface{}) primitive.A {
+	if modifiers, ok := value.(Document); ok {
+		if items, ok := modifiers["$each"].(primitive.A); ok {
+			return items
+		}
+	}
+	return primitive.A{value}
+}
+
+func containsValue(items primitive.A, value interface{}) bool {
+	for _, item := range items {
+		if valuesEqual(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesPull(item interface{}, condition interface{}) (bool, error) {
+	if operators, ok := isOperatorDocument(condition); ok {
+		return matchesOperators(item, true, operators)
+	}
+	if criteria, ok := condition.(Document); ok {
+		if document, ok := item.(Document); ok {
+			return MatchDocument(document, criteria)
+		}
+		return false, nil
+	}
+	return valuesEqual(item, condition), nil
+}
+
+func lessDocument(a Document, b Document, order bson.D) bool {
+	for _, key := range order {
+		direction, _ := toFloat(key.Value)
+		x, _ := lookupField(a, key.Key)
+		y, _ := lookupField(b, key.Key)
+		result, ok := compareValues(x, y)
+		if !ok || result == 0 {
+			continue
+		}
+		if direction < 0 {
+			return result > 0
+		}
+		return result < 0
+	}
+	return false
+}
+
+func SortDocuments(documents []Document, order bson.D) {
+	if len(order) == 0 {
+		return
+	}
+	sort.SliceStable(documents, func(i, j int) bool {
+		return lessDocument(documents[i], documents[j], order)
+	})
+}
+
+func truthy(v interface{}) bool {
+	if flag, ok := v.(bool); ok {
+		return flag
+	}
+	number, ok := toFloat(v)
+	return ok && number != 0
+}
+
+func ProjectDocument(document Document, projection Document) Document {
+	if len(projection) == 0 {
+		return document
+	}
+	include := false
+	for _, value := range projection {
+		if truthy(value) {
+			include = true
+		}
+	}
+	result := Document{}
+	if include {
+		for key, value := range projection {
+			if field, found := lookupField(document, key); found && truthy(value) {
+				setField(result, key, field)
+			}
+		}
+		if value, ok := projection["_id"]; !ok || truthy(value) {
+			result["_id"] = document["_id"]
+		}
+		return result
+	}
+	for key, value := range document {
+		result[key] = value
+	}
+	for key := range projection {
+		unsetField(result, key)
+	}
+	return result
+}
@@ -1,27 +1,242 @@
 package core
 
-type Event struct {
-	Data     interface{}
-	Listener chan interface{}
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const EventBuffer = 64
+
+const EventTimeout = time.Second
+
+var ErrBusClosed = errors.New("event bus closed")
+
+type Message struct {
+	Topic string
+	Data  interface{}
+}
+
+// Subscription receives the messages of a topic on C, closed once it ends.
+type Subscription struct {
+	C <-chan Message
+
+	bus      *EventBus
+	topic    string
+	id       uint64
+	messages chan Message
+	done     chan struct{}
+	once     sync.Once
+	mu       sync.RWMutex
+	closed   bool
+}
+
+func (s *Subscription) Topic() string {
+	return s.topic
+}
+
+func (s *Subscription) Unsubscribe() {
+	s.bus.remove(s)
+	s.close()
+}
+
+func (s *Subscription) close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.mu.Lock()
+		s.closed = true
+		close(s.messages)
+		s.mu.Unlock()
+	})
 }
 
-func InitEvent() *Event {
-	return &Event{
-		Listener: make(chan interface{}),
+func (s *Subscription) deliver(ctx context.Context, message Message) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return nil
+	}
+	select {
+	case s.messages <- message:
+		return nil
+	default:
+	}
+	select {
+	case s.messages <- message:
+		return nil
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// EventBus fans messages out to buffered subscriptions, waiting for slow ones.
+type EventBus struct {
+	mu      sync.RWMutex
+	topics  map[string]map[uint64]*Subscription
+	next    uint64
+	buffer  int
+	closed  bool
+	pending sync.WaitGroup
+}
+
+func NewEventBus(buffer int) *EventBus {
+	return &EventBus{
+		topics: make(map[string]map[uint64]*Subscription),
+		buffer: buffer,
 	}
 }
 
-func (e *Event) Publish(data interface{}) {
-	e.Data = data
+func (b *EventBus) Subscribe(topic string) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrBusClosed
+	}
+	b.next++
+	messages := make(chan Message, b.buffer)
+	subscription := &Subscription{
+		C:        messages,
+		bus:      b,
+		topic:    topic,
+		id:       b.next,
+		messages: messages,
+		done:     make(chan struct{}),
+	}
+	if b.topics[topic] == nil {
+		b.topics[topic] = make(map[uint64]*Subscription)
+	}
+	b.topics[topic][subscription.id] = subscription
+	return subscription, nil
+}
+
+func (b *EventBus) remove(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.topics[s.topic], s.id)
+	if len(b.topics[s.topic]) == 0 {
+		delete(b.topics, s.topic)
+	}
+}
+
+func (b *EventBus) HasSubscribers(topic string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.topics[topic]) > 0
+}
+
+// Publish delivers to every subscription, joining the errors of those still full
+// when ctx is done.
+func (b *EventBus) Publish(ctx context.Context, topic string, data interface{}) error {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrBusClosed
+	}
+	subscriptions := make([]*Subscription, 0, len(b.topics[topic]))
+	for _, subscription := range b.topics[topic] {
+		subscriptions = append(subscriptions, subscription)
+	}
+	b.pending.Add(1)
+	b.mu.RUnlock()
+	defer b.pending.Done()
+
+	message := Message{Topic: topic, Data: data}
+	var errs []error
+	for _, subscription := range subscriptions {
+		if err := subscription.deliver(ctx, message); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close lets subscribers drain until ctx is done, then ends every subscription.
+func (b *EventBus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	err := b.drain(ctx)
+
+	b.mu.Lock()
+	subscriptions := []*Subscription{}
+	for _, topic := range b.topics {
+		for _, subscription := range topic {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	b.topics = make(map[string]map[uint64]*Subscription)
+	b.mu.Unlock()
+
+	for _, subscription := range subscriptions {
+		subscription.close()
+	}
+	return err
+}
+
+func (b *EventBus) drain(ctx context.Context) error {
+	published := make(chan struct{})
 	go func() {
-		e.Listener <- e.Data
+		b.pending.Wait()
+		close(published)
 	}()
+	select {
+	case <-published:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if b.buffered() == 0 {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (b *EventBus) buffered() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	count := 0
+	for _, topic := range b.topics {
+		for _, subscription := range topic {
+			count += len(subscription.messages)
+		}
+	}
+	return count
 }
 
-func (e *Event) Subscribe() <-chan interface{} {
-	return e.Listener
+const (
+	EventCreated  = "created"
+	EventPatched  = "patched"
+	EventUpdated  = "updated"
+	EventRemoved  = "removed"
+	EventRestored = "restored"
+	EventPurged   = "purged"
+)
+
+type ServiceEvent struct {
+	Service  string
+	Method   string
+	Type     string
+	Document Document
+	User     string
+	Time     time.Time
 }
 
-func (e *Event) Unsubscribe() {
-	close(e.Listener)
+func EventTopic(service string, eventType string) string {
+	return service + "." + eventType
 }
@@ -0,0 +1,208 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, s *Subscription) Message {
+	t.Helper()
+	select {
+	case message, ok := <-s.C:
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return message
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+	return Message{}
+}
+
+func closed(t *testing.T, s *Subscription) {
+	t.Helper()
+	select {
+	case message, ok := <-s.C:
+		if ok {
+			t.Fatalf("unexpected message %v", message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscription not closed")
+	}
+}
+
+func TestEventBusOrdering(t *testing.T) {
+	bus := NewEventBus(4)
+	s, err := bus.Subscribe("users.created")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := bus.Subscribe("users.removed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const count = 100
+	published := make(chan error, 1)
+	go func() {
+		for i := 0; i < count; i++ {
+			if err := bus.Publish(context.Background(), "users.created", i); err != nil {
+				published <- err
+				return
+			}
+		}
+		published <- nil
+	}()
+	for i := 0; i < count; i++ {
+		message := receive(t, s)
+		if message.Topic != "users.created" || message.Data != i {
+			t.Fatalf("message %d is %v", i, message)
+		}
+	}
+	if err := <-published; err != nil {
+		t.Fatal(err)
+	}
+	if len(other.C) != 0 {
+		t.Fatalf("other topic received %d messages", len(other.C))
+	}
+}
+
+func TestEventBusBackpressure(t *testing.T) {
+	bus := NewEventBus(1)
+	slow, _ := bus.Subscribe("topic")
+	fast, _ := bus.Subscribe("topic")
+
+	if err := bus.Publish(context.Background(), "topic", 1); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, fast)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := bus.Publish(ctx, "topic", 2)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("publish to a full subscription returned %v", err)
+	}
+	if message := receive(t, fast); message.Data != 2 {
+		t.Fatalf("subscription with room received %v", message.Data)
+	}
+	if message := receive(t, slow); message.Data != 1 {
+		t.Fatalf("full subscription received %v", message.Data)
+	}
+
+	if err := bus.Publish(context.Background(), "topic", 3); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, fast)
+	published := make(chan error, 1)
+	go func() {
+		published <- bus.Publish(context.Background(), "topic", 4)
+	}()
+	select {
+	case err := <-published:
+		t.Fatalf("publish did not wait for the full subscription: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if message := receive(t, slow); message.Data != 3 {
+		t.Fatalf("full subscription received %v", message.Data)
+	}
+	if err := <-published; err != nil {
+		t.Fatal(err)
+	}
+	if message := receive(t, slow); message.Data != 4 {
+		t.Fatalf("full subscription received %v", message.Data)
+	}
+	if message := receive(t, fast); message.Data != 4 {
+		t.Fatalf("subscription with room received %v", message.Data)
+	}
+}
+
+func TestEventBusUnsubscribe(t *testing.T) {
+	bus := NewEventBus(1)
+	s, _ := bus.Subscribe("topic")
+	if !bus.HasSubscribers("topic") {
+		t.Fatal("topic has no subscribers")
+	}
+
+	bus.Publish(context.Background(), "topic", 1)
+	published := make(chan error, 1)
+	go func() {
+		published <- bus.Publish(context.Background(), "topic", 2)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	s.Unsubscribe()
+	select {
+	case err := <-published:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("unsubscribe did not release the blocked publish")
+	}
+
+	if bus.HasSubscribers("topic") {
+		t.Fatal("topic still has subscribers")
+	}
+	if message := receive(t, s); message.Data != 1 {
+		t.Fatalf("buffered message is %v", message.Data)
+	}
+	closed(t, s)
+	s.Unsubscribe()
+	if err := bus.Publish(context.Background(), "topic", 3); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEventBusCloseDrains(t *testing.T) {
+	bus := NewEventBus(8)
+	s, _ := bus.Subscribe("topic")
+	for i := 0; i < 5; i++ {
+		if err := bus.Publish(context.Background(), "topic", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	received := make(chan []interface{}, 1)
+	go func() {
+		values := []interface{}{}
+		for message := range s.C {
+			time.Sleep(5 * time.Millisecond)
+			values = append(values, message.Data)
+		}
+		received <- values
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := bus.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if values := <-received; len(values) != 5 {
+		t.Fatalf("drained %v", values)
+	}
+
+	if err := bus.Publish(context.Background(), "topic", 5); !errors.Is(err, ErrBusClosed) {
+		t.Fatalf("publish after close returned %v", err)
+	}
+	if _, err := bus.Subscribe("topic"); !errors.Is(err, ErrBusClosed) {
+		t.Fatalf("subscribe after close returned %v", err)
+	}
+	if err := bus.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEventBusCloseTimeout(t *testing.T) {
+	bus := NewEventBus(2)
+	s, _ := bus.Subscribe("topic")
+	bus.Publish(context.Background(), "topic", 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if err := bus.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("close of an undrained bus returned %v", err)
+	}
+	receive(t, s)
+	closed(t, s)
+}
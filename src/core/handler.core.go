@@ -0,0 +1,301 @@
+package core
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Page is a page of results. Total is nil when the page was not counted.
+type Page[T any] struct {
+	Data      []T    `json:"data"`
+	Total     *int64 `json:"total,omitempty"`
+	Estimated bool   `json:"estimated,omitempty"`
+	Limit     int64  `json:"limit"`
+	Skip      int64  `json:"skip"`
+	Next      string `json:"next,omitempty"`
+	Prev      string `json:"prev,omitempty"`
+}
+
+func MapPage[T any, R any](p Page[T], mapper func(*T) R) Page[R] {
+	data := make([]R, 0, len(p.Data))
+	for i := range p.Data {
+		data = append(data, mapper(&p.Data[i]))
+	}
+	return Page[R]{
+		Data:      data,
+		Total:     p.Total,
+		Estimated: p.Estimated,
+		Limit:     p.Limit,
+		Skip:      p.Skip,
+		Next:      p.Next,
+		Prev:      p.Prev,
+	}
+}
+
+// TypedHandler wraps a Handler and decodes every result into T.
+type TypedHandler[T any] struct {
+	Handler Handler
+}
+
+func Typed[T any](h Handler) TypedHandler[T] {
+	return TypedHandler[T]{Handler: h}
+}
+
+func (t TypedHandler[T]) Find(customFilter interface{}, customOptions FindHandlerOptions) ([]T, error) {
+	response := t.Handler.Find(customFilter, customOptions)
+	if response.Exception != nil {
+		return nil, response.Exception
+	}
+	results := []T{}
+	if err := response.Result.All(t.Handler.Context(), &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Paginate finds a page of documents in the mode of pageOptions.
+func (t TypedHandler[T]) Paginate(customFilter interface{}, customOptions FindHandlerOptions, pageOptions PageOptions) (Page[T], error) {
+	if pageOptions.Mode == PageCursor {
+		return t.CursorPage(customFilter, customOptions, pageOptions)
+	}
+	if pageOptions.Cursor != "" {
+		return Page[T]{}, fmt.Errorf("%w: cursors need cursor pagination", ErrInvalidCursor)
+	}
+	data, err := t.Find(customFilter, customOptions)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	page := Page[T]{Data: data}
+	if customOptions != nil && customOptions.Limit != nil {
+		page.Limit = *customOptions.Limit
+	}
+	if customOptions != nil && customOptions.Skip != nil {
+		page.Skip = *customOptions.Skip
+	}
+	if err := t.count(&page, customFilter, pageOptions.Count); err != nil {
+		return Page[T]{}, err
+	}
+	return page, nil
+}
+
+func (t TypedHandler[T]) count(page *Page[T], customFilter interface{}, mode CountMode) error {
+	var response CountHandlerResponse
+	switch mode {
+	case CountNone:
+		return nil
+	case CountEstimated:
+		response = t.Handler.EstimatedCount(options.EstimatedDocumentCount())
+		page.Estimated = true
+	default:
+		response = t.Handler.Count(customFilter, options.Count())
+	}
+	if response.Exception != nil {
+		return response.Exception
+	}
+	page.Total = &response.Result
+	return nil
+}
+
+func (t TypedHandler[T]) Aggregate(customPipeline interface{}, customOptions AggregateHandlerOptions) ([]T, error) {
+	response := t.Handler.Aggregate(customPipeline, customOptions)
+	if response.Exception != nil {
+		return nil, response.Exception
+	}
+	results := []T{}
+	if err := response.Result.All(t.Handler.Context(), &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// AggregatePage pages the output of a pipeline.
+func (t TypedHandler[T]) AggregatePage(customPipeline mongo.Pipeline, limit int64, skip int64) (Page[T], error) {
+	paged := append(mongo.Pipeline{}, customPipeline...)
+	paged = append(paged, bson.D{{Key: "$skip", Value: skip}})
+	if limit > 0 {
+		paged = append(paged, bson.D{{Key: "$limit", Value: limit}})
+	}
+	data, err := t.Aggregate(paged, options.Aggregate())
+	if err != nil {
+		return Page[T]{}, err
+	}
+	counted := append(mongo.Pipeline{}, customPipeline...)
+	counted = append(counted, bson.D{{Key: "$count", Value: "total"}})
+	response := t.Handler.Aggregate(counted, options.Aggregate())
+	if response.Exception != nil {
+		return Page[T]{}, response.Exception
+	}
+	counts := []struct {
+		Total int64 `bson:"total"`
+	}{}
+	if err := response.Result.All(t.Handler.Context(), &counts); err != nil {
+		return Page[T]{}, err
+	}
+	var total int64
+	if len(counts) > 0 {
+		total = counts[0].Total
+	}
+	return Page[T]{
+		Data:  data,
+		Total: &total,
+		Limit: limit,
+		Skip:  skip,
+	}, nil
+}
+
+func (t TypedHandler[T]) Get(customFilter interface{}, customOptions GetHandlerOptions) (T, error) {
+	var result T
+	response := t.Handler.Get(customFilter, customOptions)
+	if response.Exception != nil {
+		return result, response.Exception
+	}
+	err := response.Result.Decode(&result)
+	return result, err
+}
+
+func (t TypedHandler[T]) Create(customPayload interface{}, customOptions CreateHandlerOptions) (T, error) {
+	var result T
+	response := t.Handler.Create(customPayload, customOptions)
+	if response.Exception != nil {
+		return result, response.Exception
+	}
+	filter := map[string]interface{}{"_id": response.Result.InsertedID}
+	return t.Get(filter, options.FindOne())
+}
+
+func (t TypedHandler[T]) Patch(customFilter interface{}, customPayload interface{}, customOptions PatchHandlerOptions) (T, error) {
+	var result T
+	response := t.Handler.Patch(customFilter, customPayload, customOptions)
+	if response.Exception != nil {
+		return result, response.Exception
+	}
+	err := response.Result.Decode(&result)
+	return result, err
+}
+
+func (t TypedHandler[T]) Update(customFilter interface{}, customPayload interface{}, customOptions UpdateHandlerOptions) (T, error) {
+	var result T
+	response := t.Handler.Update(customFilter, customPayload, customOptions)
+	if response.Exception != nil {
+		return result, response.Exception
+	}
+	err := response.Result.Decode(&result)
+	return result, err
+}
+
+func (t TypedHandler[T]) Delete(customFilter interface{}, customOptions DeleteHandlerOptions) (T, error) {
+	var result T
+	response := t.Handler.Delete(customFilter, customOptions)
+	if response.Exception != nil {
+		return result, response.Exception
+	}
+	err := response.Result.Decode(&result)
+	return result, err
+}
+
+func (t TypedHandler[T]) Restore(customFilter interface{}, customOptions PatchHandlerOptions) (T, error) {
+	var result T
+	response := t.Handler.Restore(customFilter, customOptions)
+	if response.Exception != nil {
+		return result, response.Exception
+	}
+	err := response.Result.Decode(&result)
+	return result, err
+}
+
+func (t TypedHandler[T]) Purge(customFilter interface{}, customOptions DeleteHandlerOptions) (T, error) {
+	var result T
+	response := t.Handler.Purge(customFilter, customOptions)
+	if response.Exception != nil {
+		return result, response.Exception
+	}
+	err := response.Result.Decode(&result)
+	return result, err
+}
+
+type BulkResult[T any] struct {
+	Index int   `json:"index"`
+	Data  *T    `json:"data,omitempty"`
+	Error error `json:"error,omitempty"`
+}
+
+// Bulk holds the per document results of a multi operation.
+type Bulk[T any] []BulkResult[T]
+
+// BulkResults lets hooks visit the items of any Bulk.
+type BulkResults interface {
+	Len() int
+	Item(i int) (interface{}, bool)
+	SetItem(i int, v interface{})
+	SetError(i int, err error)
+}
+
+func (b Bulk[T]) Len() int {
+	return len(b)
+}
+
+func (b Bulk[T]) Item(i int) (interface{}, bool) {
+	if b[i].Data == nil {
+		return nil, false
+	}
+	return *b[i].Data, true
+}
+
+func (b Bulk[T]) SetItem(i int, v interface{}) {
+	if data, ok := v.(T); ok {
+		b[i].Data = &data
+	}
+}
+
+func (b Bulk[T]) SetError(i int, err error) {
+	b[i].Data = nil
+	b[i].Error = err
+}
+
+func MapBulk[T any, R any](b Bulk[T], mapper func(*T) R) Bulk[R] {
+	results := make(Bulk[R], 0, len(b))
+	for _, item := range b {
+		result := BulkResult[R]{Index: item.Index, Error: item.Error}
+		if item.Data != nil {
+			data := mapper(item.Data)
+			result.Data = &data
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func decodeBulk[T any](response BulkHandlerResponse) (Bulk[T], error) {
+	if response.Exception != nil {
+		return nil, response.Exception
+	}
+	results := make(Bulk[T], 0, len(response.Result))
+	for _, item := range response.Result {
+		result := BulkResult[T]{Index: item.Index, Error: item.Exception}
+		if item.Exception == nil {
+			var data T
+			if err := item.Result.Decode(&data); err != nil {
+				result.Error = err
+			} else {
+				result.Data = &data
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (t TypedHandler[T]) CreateMany(customPayloads []interface{}, customOptions CreateManyHandlerOptions) (Bulk[T], error) {
+	return decodeBulk[T](t.Handler.CreateMany(customPayloads, customOptions))
+}
+
+func (t TypedHandler[T]) PatchMany(customFilter interface{}, customPayload interface{}, customOptions PatchHandlerOptions) (Bulk[T], error) {
+	return decodeBulk[T](t.Handler.PatchMany(customFilter, customPayload, customOptions))
+}
+
+func (t TypedHandler[T]) DeleteMany(customFilter interface{}, customOptions DeleteHandlerOptions) (Bulk[T], error) {
+	return decodeBulk[T](t.Handler.DeleteMany(customFilter, customOptions))
+}
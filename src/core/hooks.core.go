@@ -0,0 +1,162 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const MethodAll = "ALL"
+
+const (
+	HookBefore = "before"
+	HookAfter  = "after"
+	HookError  = "error"
+)
+
+const ProviderRest = "rest"
+
+// Halt stops a hook chain without an error. From a before hook it also skips
+// the controller, the hook setting Result or writing the response itself.
+var Halt = errors.New("hook chain halted")
+
+type Params struct {
+	Query    url.Values
+	Route    map[string]string
+	User     string
+	Provider string
+	// Version makes the writes of internal calls conditional, as If-Match does.
+	Version *int64
+}
+
+// HookContext is shared by the hooks of a call. Ctx is nil for internal calls.
+// Service and Method are read-only, and OnError hooks clearing Error must set
+// a Result.
+type HookContext struct {
+	App     *App
+	Service *Service
+	Method  string
+	Type    string
+	Ctx     *fiber.Ctx
+	Data    interface{}
+	Params  Params
+	Result  interface{}
+	Error   error
+}
+
+type HookFunc func(hc *HookContext) error
+
+// HookChain holds the hooks of each method, MethodAll ones first.
+type HookChain map[string][]HookFunc
+
+// Hooks run in the following order for a service method call:
+//
+//	before:  app ALL, app method, service ALL, service method
+//	after:   service ALL, service method, app ALL, app method
+//	onError: service ALL, service method, app ALL, app method
+type Hooks struct {
+	Before  HookChain
+	After   HookChain
+	OnError HookChain
+}
+
+func (h HookChain) For(method string) []HookFunc {
+	hooks := make([]HookFunc, 0, len(h[MethodAll])+len(h[method]))
+	hooks = append(hooks, h[MethodAll]...)
+	return append(hooks, h[method]...)
+}
+
+// runHooks fails the call when a hook changes its service or method.
+func runHooks(hooks []HookFunc, hc *HookContext) error {
+	service, method := hc.Service, hc.Method
+	for _, hook := range hooks {
+		err := hook(hc)
+		if hc.Service != service || hc.Method != method {
+			return Unexpected("hooks cannot change the service or method of a call")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DataAs decodes the hook data into T.
+func DataAs[T any](hc *HookContext) (T, error) {
+	var data T
+	if typed, ok := hc.Data.(T); ok {
+		return typed, nil
+	}
+	body, err := json.Marshal(hc.Data)
+	if err != nil {
+		return data, err
+	}
+	err = json.Unmarshal(body, &data)
+	return data, err
+}
+
+func parseData(c *fiber.Ctx) interface{} {
+	body := c.Body()
+	if len(body) == 0 || !strings.Contains(string(c.Request().Header.ContentType()), "json") {
+		return nil
+	}
+	var data interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return nil
+	}
+	return data
+}
+
+func unparsed(hc *HookContext) bool {
+	return hc.Data == nil && hc.Ctx != nil && len(bytes.TrimSpace(hc.Ctx.Body())) > 0
+}
+
+func queryValues(c *fiber.Ctx) url.Values {
+	values := url.Values{}
+	c.Request().URI().QueryArgs().VisitAll(func(key, value []byte) {
+		values.Add(string(key), string(value))
+	})
+	return values
+}
+
+func copyParams(values url.Values) url.Values {
+	clone := make(url.Values, len(values))
+	for key, value := range values {
+		clone[key] = append([]string(nil), value...)
+	}
+	return clone
+}
+
+func runHooksEach(hooks []HookFunc, hc *HookContext, items []interface{}) error {
+	for i, item := range items {
+		each := *hc
+		each.Data = item
+		if err := runHooks(hooks, &each); err != nil {
+			return err
+		}
+		items[i] = each.Data
+	}
+	return nil
+}
+
+func runResultHooksEach(hooks []HookFunc, hc *HookContext, results BulkResults) {
+	for i := 0; i < results.Len(); i++ {
+		item, ok := results.Item(i)
+		if !ok {
+			continue
+		}
+		each := *hc
+		each.Result = item
+		if err := runHooks(hooks, &each); err != nil && err != Halt {
+			results.SetError(i, err)
+			continue
+		}
+		results.SetItem(i, each.Result)
+	}
+}
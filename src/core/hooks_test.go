@@ -0,0 +1,54 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestErrorHooks(t *testing.T) {
+	missing := "/records/" + primitive.NewObjectID().Hex()
+	cases := map[string]struct {
+		hook   HookFunc
+		status int
+	}{
+		"cleared without a result": {func(hc *HookContext) error { hc.Error = nil; return nil }, fiber.StatusInternalServerError},
+		"cleared with a result":    {func(hc *HookContext) error { hc.Error, hc.Result = nil, record{Name: "fallback"}; return nil }, fiber.StatusOK},
+		"replaced":                 {func(hc *HookContext) error { hc.Error = Forbidden("replaced"); return nil }, fiber.StatusForbidden},
+		"kept":                     {func(hc *HookContext) error { return nil }, fiber.StatusNotFound},
+	}
+	for name, c := range cases {
+		records := newService("records", record{}).
+			SetResource(CRUD[record, record]{}).
+			SetHooks(Hooks{OnError: HookChain{MethodGet: {c.hook}}})
+		engine := serve(InitApp(), records)
+		if status, _, out := send(t, engine, "GET", missing, nil, "X-User", "user"); status != c.status {
+			t.Errorf("%s: get returned %d %v", name, status, out)
+		}
+	}
+}
+
+func TestHooksCannotChangeServiceOrMethod(t *testing.T) {
+	other := newService("others", record{})
+	hooks := map[string]Hooks{
+		"before method":  {Before: HookChain{MethodCreate: {func(hc *HookContext) error { hc.Method = MethodDelete; return nil }}}},
+		"before service": {Before: HookChain{MethodCreate: {func(hc *HookContext) error { hc.Service = other; return nil }}}},
+		"after method":   {After: HookChain{MethodCreate: {func(hc *HookContext) error { hc.Method = MethodPatch; return nil }}}},
+	}
+	for name, h := range hooks {
+		records := newService("records", record{}).SetResource(CRUD[record, record]{}).SetHooks(h)
+		engine := serve(InitApp(), records)
+		if status, _, out := send(t, engine, "POST", "/records", map[string]interface{}{"name": "a"}, "X-User", "user"); status != fiber.StatusInternalServerError {
+			t.Errorf("%s: create returned %d %v", name, status, out)
+		}
+		count, err := records.Entity.Adapter.CountDocuments(context.Background(), Document{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if before := h.Before != nil; before && count != 0 {
+			t.Errorf("%s: stored %d records", name, count)
+		}
+	}
+}
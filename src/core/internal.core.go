@@ -0,0 +1,154 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// APIPrefix is the path the service routes are mounted on.
+const APIPrefix = "/api/v1"
+
+const ProviderInternal = "internal"
+
+func (s *Service) route(verb string, id string) (Route, error) {
+	path := s.Path
+	if id != "" {
+		path += "/:id"
+	}
+	for _, route := range s.Router {
+		if Verbs[route.Method] == verb && routePattern(route.Path) == routePattern(path) {
+			return route, nil
+		}
+	}
+	return Route{}, fmt.Errorf("service %s has no %s %s route", s.Name, verb, path)
+}
+
+// call runs a route in process through the same pipeline as a REST request,
+// without authentication.
+func (s *Service) call(ctx context.Context, verb string, id string, data interface{}, params Params) (interface{}, error) {
+	route, err := s.route(verb, id)
+	if err != nil {
+		return nil, err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if params.Query == nil {
+		params.Query = url.Values{}
+	}
+	params.Route = map[string]string{}
+	if id != "" {
+		params.Route["id"] = id
+	}
+	params.Provider = ProviderInternal
+	handler := s.Handler.WithContext(ctx).As(params.User)
+	if params.Version != nil && s.Versioning {
+		handler = handler.IfVersion(*params.Version)
+	}
+	cc := &ControllerContext{
+		Context:   ctx,
+		Method:    route.Method,
+		Handler:   handler,
+		Service:   s,
+		Entity:    s.Entity,
+		App:       s.App,
+		Principal: Principal{User: params.User, Internal: true},
+		Data:      data,
+		Params:    params,
+		rawQuery:  params.Query.Encode(),
+	}
+	return s.dispatch(route, cc)
+}
+
+func (s *Service) Find(ctx context.Context, params Params) (interface{}, error) {
+	return s.call(ctx, fiber.MethodGet, "", nil, params)
+}
+
+func (s *Service) Get(ctx context.Context, id string, params Params) (interface{}, error) {
+	return s.call(ctx, fiber.MethodGet, id, nil, params)
+}
+
+// Create creates a document, or every item of []interface{} data.
+func (s *Service) Create(ctx context.Context, data interface{}, params Params) (interface{}, error) {
+	return s.call(ctx, fiber.MethodPost, "", data, params)
+}
+
+// Patch patches the document id, or those matching params.Query.
+func (s *Service) Patch(ctx context.Context, id string, data interface{}, params Params) (interface{}, error) {
+	return s.call(ctx, fiber.MethodPatch, id, data, params)
+}
+
+func (s *Service) Update(ctx context.Context, id string, data interface{}, params Params) (interface{}, error) {
+	return s.call(ctx, fiber.MethodPut, id, data, params)
+}
+
+// Remove deletes the document id, or those matching params.Query.
+func (s *Service) Remove(ctx context.Context, id string, params Params) (interface{}, error) {
+	return s.call(ctx, fiber.MethodDelete, id, nil, params)
+}
+
+// TypedService makes the internal calls of a service responding with R.
+type TypedService[R any] struct {
+	Service *Service
+}
+
+func Calls[R any](s *Service) TypedService[R] {
+	return TypedService[R]{Service: s}
+}
+
+// resultAs converts the results replaced by hooks through JSON.
+func resultAs[V any](result interface{}, err error) (V, error) {
+	var value V
+	if err != nil {
+		return value, err
+	}
+	if typed, ok := result.(V); ok {
+		return typed, nil
+	}
+	body, err := json.Marshal(result)
+	if err != nil {
+		return value, err
+	}
+	err = json.Unmarshal(body, &value)
+	return value, err
+}
+
+func (t TypedService[R]) Find(ctx context.Context, params Params) (Page[R], error) {
+	return resultAs[Page[R]](t.Service.Find(ctx, params))
+}
+
+func (t TypedService[R]) Get(ctx context.Context, id string, params Params) (R, error) {
+	return resultAs[R](t.Service.Get(ctx, id, params))
+}
+
+func (t TypedService[R]) Create(ctx context.Context, data interface{}, params Params) (R, error) {
+	return resultAs[R](t.Service.Create(ctx, data, params))
+}
+
+func (t TypedService[R]) CreateMany(ctx context.Context, items []interface{}, params Params) (Bulk[R], error) {
+	return resultAs[Bulk[R]](t.Service.Create(ctx, items, params))
+}
+
+func (t TypedService[R]) Patch(ctx context.Context, id string, data interface{}, params Params) (R, error) {
+	return resultAs[R](t.Service.Patch(ctx, id, data, params))
+}
+
+func (t TypedService[R]) PatchMany(ctx context.Context, data interface{}, params Params) (Bulk[R], error) {
+	return resultAs[Bulk[R]](t.Service.Patch(ctx, "", data, params))
+}
+
+func (t TypedService[R]) Update(ctx context.Context, id string, data interface{}, params Params) (R, error) {
+	return resultAs[R](t.Service.Update(ctx, id, data, params))
+}
+
+func (t TypedService[R]) Remove(ctx context.Context, id string, params Params) (R, error) {
+	return resultAs[R](t.Service.Remove(ctx, id, params))
+}
+
+func (t TypedService[R]) RemoveMany(ctx context.Context, params Params) (Bulk[R], error) {
+	return resultAs[Bulk[R]](t.Service.Remove(ctx, "", params))
+}
@@ -0,0 +1,470 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const DuplicateKeyCode = 11000
+
+type MemoryAdapter struct {
+	mu        sync.RWMutex
+	documents []Document
+	unique    []string
+}
+
+func NewMemoryAdapter() *MemoryAdapter {
+	return &MemoryAdapter{}
+}
+
+type MemoryStorage struct {
+	mu          sync.Mutex
+	collections map[string]*MemoryAdapter
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{collections: make(map[string]*MemoryAdapter)}
+}
+
+func (m *MemoryStorage) Collection(name string, schema interface{}) Adapter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	collection, ok := m.collections[name]
+	if !ok {
+		collection = NewMemoryAdapter()
+		m.collections[name] = collection
+	}
+	return collection
+}
+
+func cloneDocument(document Document) Document {
+	clone, err := ToDocument(document)
+	if err != nil {
+		return Document{}
+	}
+	return clone
+}
+
+func orderOf(sorting interface{}) (bson.D, error) {
+	if sorting == nil {
+		return nil, nil
+	}
+	return toOrderedDocument(sorting)
+}
+
+func projectionOf(projection interface{}) (Document, error) {
+	if projection == nil {
+		return nil, nil
+	}
+	return ToDocument(projection)
+}
+
+// query returns the matching indexes in sort order. Callers hold the lock.
+func (m *MemoryAdapter) query(filter interface{}, sorting interface{}) ([]int, error) {
+	criteria, err := ToDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	order, err := orderOf(sorting)
+	if err != nil {
+		return nil, err
+	}
+	matches := []int{}
+	for i, document := range m.documents {
+		matched, err := MatchDocument(document, criteria)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			matches = append(matches, i)
+		}
+	}
+	if len(order) > 0 {
+		sort.SliceStable(matches, func(a, b int) bool {
+			return lessDocument(m.documents[matches[a]], m.documents[matches[b]], order)
+		})
+	}
+	return matches, nil
+}
+
+// conflicts ignores the document at index skip. Callers hold the lock.
+func (m *MemoryAdapter) conflicts(document Document, skip int) string {
+	keys := append([]string{"_id"}, m.unique...)
+	for _, key := range keys {
+		value, found := lookupField(document, key)
+		if !found {
+			continue
+		}
+		for i, existing := range m.documents {
+			if i == skip {
+				continue
+			}
+			if current, ok := lookupField(existing, key); ok && valuesEqual(current, value) {
+				return key
+			}
+		}
+	}
+	return ""
+}
+
+func duplicateKeyMessage(key string) string {
+	return fmt.Sprintf("E11000 duplicate key error dup key: { %s }", key)
+}
+
+func (m *MemoryAdapter) Find(ctx context.Context, filter interface{}, opts FindHandlerOptions) (*mongo.Cursor, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if opts == nil {
+		opts = options.Find()
+	}
+	matches, err := m.query(filter, opts.Sort)
+	if err != nil {
+		return nil, err
+	}
+	projection, err := projectionOf(opts.Projection)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Skip != nil && *opts.Skip > 0 {
+		if int(*opts.Skip) >= len(matches) {
+			matches = matches[:0]
+		} else {
+			matches = matches[*opts.Skip:]
+		}
+	}
+	if opts.Limit != nil && *opts.Limit > 0 && int(*opts.Limit) < len(matches) {
+		matches = matches[:*opts.Limit]
+	}
+	documents := make([]interface{}, 0, len(matches))
+	for _, i := range matches {
+		documents = append(documents, ProjectDocument(cloneDocument(m.documents[i]), projection))
+	}
+	return mongo.NewCursorFromDocuments(documents, nil, nil)
+}
+
+func (m *MemoryAdapter) FindOne(ctx context.Context, filter interface{}, opts GetHandlerOptions) *mongo.SingleResult {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if opts == nil {
+		opts = options.FindOne()
+	}
+	matches, err := m.query(filter, opts.Sort)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	if opts.Skip != nil && *opts.Skip > 0 {
+		if int(*opts.Skip) >= len(matches) {
+			matches = matches[:0]
+		} else {
+			matches = matches[*opts.Skip:]
+		}
+	}
+	if len(matches) == 0 {
+		return mongo.NewSingleResultFromDocument(Document{}, mongo.ErrNoDocuments, nil)
+	}
+	projection, err := projectionOf(opts.Projection)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	return mongo.NewSingleResultFromDocument(ProjectDocument(cloneDocument(m.documents[matches[0]]), projection), nil, nil)
+}
+
+func (m *MemoryAdapter) InsertOne(ctx context.Context, document interface{}, opts CreateHandlerOptions) (*mongo.InsertOneResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, err := ToDocument(document)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := record["_id"]; !ok {
+		record["_id"] = primitive.NewObjectID()
+	}
+	if key := m.conflicts(record, -1); key != "" {
+		return nil, mongo.WriteException{WriteErrors: mongo.WriteErrors{{Index: 0, Code: DuplicateKeyCode, Message: duplicateKeyMessage(key)}}}
+	}
+	m.documents = append(m.documents, record)
+	return &mongo.InsertOneResult{InsertedID: record["_id"]}, nil
+}
+
+func (m *MemoryAdapter) InsertMany(ctx context.Context, documents []interface{}, opts CreateManyHandlerOptions) (*mongo.InsertManyResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ordered := opts == nil || opts.Ordered == nil || *opts.Ordered
+	result := &mongo.InsertManyResult{}
+	failures := mongo.BulkWriteException{}
+	for i, document := range documents {
+		record, err := ToDocument(document)
+		if err != nil {
+			return result, err
+		}
+		if _, ok := record["_id"]; !ok {
+			record["_id"] = primitive.NewObjectID()
+		}
+		if key := m.conflicts(record, -1); key != "" {
+			failures.WriteErrors = append(failures.WriteErrors, mongo.BulkWriteError{
+				WriteError: mongo.WriteError{Index: i, Code: DuplicateKeyCode, Message: duplicateKeyMessage(key)},
+			})
+			if ordered {
+				break
+			}
+			continue
+		}
+		m.documents = append(m.documents, record)
+		result.InsertedIDs = append(result.InsertedIDs, record["_id"])
+	}
+	if len(failures.WriteErrors) > 0 {
+		return result, failures
+	}
+	return result, nil
+}
+
+func upsertDocument(filter interface{}, update Document) (Document, error) {
+	criteria, err := ToDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	record := Document{}
+	for key, value := range criteria {
+		if strings.HasPrefix(key, "$") {
+			continue
+		}
+		if _, ok := isOperatorDocument(value); ok {
+			continue
+		}
+		setField(record, key, value)
+	}
+	if inserts, ok := update["$setOnInsert"]; ok {
+		if err := UpdateDocument(record, Document{"$set": inserts}); err != nil {
+			return nil, err
+		}
+	}
+	if err := UpdateDocument(record, update); err != nil {
+		return nil, err
+	}
+	if _, ok := record["_id"]; !ok {
+		record["_id"] = primitive.NewObjectID()
+	}
+	return record, nil
+}
+
+func replaceDocument(current Document, replacement Document) (Document, error) {
+	record := cloneDocument(replacement)
+	if id, ok := record["_id"]; ok && !valuesEqual(id, current["_id"]) {
+		return nil, fmt.Errorf("the immutable field '_id' cannot be modified")
+	}
+	record["_id"] = current["_id"]
+	return record, nil
+}
+
+func upsertReplacement(filter interface{}, replacement Document) (Document, error) {
+	record := cloneDocument(replacement)
+	if _, ok := record["_id"]; ok {
+		return record, nil
+	}
+	criteria, err := ToDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	if id, ok := criteria["_id"]; ok {
+		if _, ok := isOperatorDocument(id); !ok {
+			record["_id"] = id
+			return record, nil
+		}
+	}
+	record["_id"] = primitive.NewObjectID()
+	return record, nil
+}
+
+func (m *MemoryAdapter) FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts PatchHandlerOptions) *mongo.SingleResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if opts == nil {
+		opts = options.FindOneAndUpdate()
+	}
+	changes, err := ToDocument(update)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	matches, err := m.query(filter, opts.Sort)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	projection, err := projectionOf(opts.Projection)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	after := opts.ReturnDocument != nil && *opts.ReturnDocument == options.After
+	if len(matches) == 0 {
+		if opts.Upsert == nil || !*opts.Upsert {
+			return mongo.NewSingleResultFromDocument(Document{}, mongo.ErrNoDocuments, nil)
+		}
+		record, err := upsertDocument(filter, changes)
+		if err != nil {
+			return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+		}
+		if key := m.conflicts(record, -1); key != "" {
+			return mongo.NewSingleResultFromDocument(Document{}, mongo.CommandError{Code: DuplicateKeyCode, Message: duplicateKeyMessage(key)}, nil)
+		}
+		m.documents = append(m.documents, record)
+		if !after {
+			return mongo.NewSingleResultFromDocument(Document{}, mongo.ErrNoDocuments, nil)
+		}
+		return mongo.NewSingleResultFromDocument(ProjectDocument(cloneDocument(record), projection), nil, nil)
+	}
+	index := matches[0]
+	before := m.documents[index]
+	record := cloneDocument(before)
+	if err := UpdateDocument(record, changes); err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	if key := m.conflicts(record, index); key != "" {
+		return mongo.NewSingleResultFromDocument(Document{}, mongo.CommandError{Code: DuplicateKeyCode, Message: duplicateKeyMessage(key)}, nil)
+	}
+	m.documents[index] = record
+	if after {
+		return mongo.NewSingleResultFromDocument(ProjectDocument(cloneDocument(record), projection), nil, nil)
+	}
+	return mongo.NewSingleResultFromDocument(ProjectDocument(cloneDocument(before), projection), nil, nil)
+}
+
+func (m *MemoryAdapter) FindOneAndReplace(ctx context.Context, filter interface{}, replacement interface{}, opts UpdateHandlerOptions) *mongo.SingleResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if opts == nil {
+		opts = options.FindOneAndReplace()
+	}
+	document, err := ToDocument(replacement)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	matches, err := m.query(filter, opts.Sort)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	projection, err := projectionOf(opts.Projection)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	after := opts.ReturnDocument != nil && *opts.ReturnDocument == options.After
+	if len(matches) == 0 {
+		if opts.Upsert == nil || !*opts.Upsert {
+			return mongo.NewSingleResultFromDocument(Document{}, mongo.ErrNoDocuments, nil)
+		}
+		record, err := upsertReplacement(filter, document)
+		if err != nil {
+			return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+		}
+		if key := m.conflicts(record, -1); key != "" {
+			return mongo.NewSingleResultFromDocument(Document{}, mongo.CommandError{Code: DuplicateKeyCode, Message: duplicateKeyMessage(key)}, nil)
+		}
+		m.documents = append(m.documents, record)
+		if !after {
+			return mongo.NewSingleResultFromDocument(Document{}, mongo.ErrNoDocuments, nil)
+		}
+		return mongo.NewSingleResultFromDocument(ProjectDocument(cloneDocument(record), projection), nil, nil)
+	}
+	index := matches[0]
+	before := m.documents[index]
+	record, err := replaceDocument(before, document)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	if key := m.conflicts(record, index); key != "" {
+		return mongo.NewSingleResultFromDocument(Document{}, mongo.CommandError{Code: DuplicateKeyCode, Message: duplicateKeyMessage(key)}, nil)
+	}
+	m.documents[index] = record
+	if after {
+		return mongo.NewSingleResultFromDocument(ProjectDocument(cloneDocument(record), projection), nil, nil)
+	}
+	return mongo.NewSingleResultFromDocument(ProjectDocument(cloneDocument(before), projection), nil, nil)
+}
+
+func (m *MemoryAdapter) FindOneAndDelete(ctx context.Context, filter interface{}, opts DeleteHandlerOptions) *mongo.SingleResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if opts == nil {
+		opts = options.FindOneAndDelete()
+	}
+	matches, err := m.query(filter, opts.Sort)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	if len(matches) == 0 {
+		return mongo.NewSingleResultFromDocument(Document{}, mongo.ErrNoDocuments, nil)
+	}
+	projection, err := projectionOf(opts.Projection)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	index := matches[0]
+	record := m.documents[index]
+	m.documents = append(m.documents[:index], m.documents[index+1:]...)
+	return mongo.NewSingleResultFromDocument(ProjectDocument(record, projection), nil, nil)
+}
+
+func (m *MemoryAdapter) CountDocuments(ctx context.Context, filter interface{}, opts CountHandlerOptions) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	matches, err := m.query(filter, nil)
+	if err != nil {
+		return 0, err
+	}
+	count := int64(len(matches))
+	if opts != nil && opts.Skip != nil {
+		count -= *opts.Skip
+		if count < 0 {
+			count = 0
+		}
+	}
+	if opts != nil && opts.Limit != nil && *opts.Limit > 0 && count > *opts.Limit {
+		count = *opts.Limit
+	}
+	return count, nil
+}
+
+func (m *MemoryAdapter) EstimatedDocumentCount(ctx context.Context, opts EstimatedCountHandlerOptions) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return int64(len(m.documents)), nil
+}
+
+func (m *MemoryAdapter) Aggregate(ctx context.Context, pipeline interface{}, opts AggregateHandlerOptions) (*mongo.Cursor, error) {
+	stages, err := ToStages(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	documents := make([]Document, 0, len(m.documents))
+	for _, document := range m.documents {
+		documents = append(documents, cloneDocument(document))
+	}
+	m.mu.RUnlock()
+	results, err := AggregateDocuments(documents, stages)
+	if err != nil {
+		return nil, err
+	}
+	return cursorOf(results)
+}
+
+func (m *MemoryAdapter) CreateIndex(ctx context.Context, key string, unique bool) error {
+	if !unique {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.unique {
+		if existing == key {
+			return nil
+		}
+	}
+	m.unique = append(m.unique, key)
+	return nil
+}
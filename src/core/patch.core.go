@@ -0,0 +1,395 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+// AllowedOperators are the mongo update operators a Patch may use.
+var AllowedOperators = map[string]bool{
+	"$set":      true,
+	"$unset":    true,
+	"$inc":      true,
+	"$push":     true,
+	"$pull":     true,
+	"$addToSet": true,
+	"$min":      true,
+	"$max":      true,
+	"$mul":      true,
+}
+
+// Operators is an update document, applied by Handler.Patch as is.
+type Operators bson.M
+
+func (o Operators) Validate() error {
+	if len(o) == 0 {
+		return fmt.Errorf("update document must have at least one operator")
+	}
+	for operator, fields := range o {
+		if !AllowedOperators[operator] {
+			return fmt.Errorf("unsupported update operator %s", operator)
+		}
+		values, err := ToDocument(fields)
+		if err != nil {
+			return fmt.Errorf("%s needs a document", operator)
+		}
+		for path := range values {
+			if path == "" || strings.HasPrefix(path, "$") {
+				return fmt.Errorf("%s: invalid field name %q", operator, path)
+			}
+		}
+	}
+	return nil
+}
+
+// Omit drops the changes to the given fields and their nested fields.
+func (o Operators) Omit(fields ...string) Operators {
+	result := Operators{}
+	for operator, changes := range o {
+		values, err := ToDocument(changes)
+		if err != nil {
+			result[operator] = changes
+			continue
+		}
+		for path := range values {
+			for _, field := range fields {
+				if path == field || strings.HasPrefix(path, field+".") {
+					delete(values, path)
+				}
+			}
+		}
+		if len(values) > 0 {
+			result[operator] = values
+		}
+	}
+	return result
+}
+
+func decodeJSON(body []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// fromJSON reads extended JSON wrappers such as {"$oid": ...}.
+func fromJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(map[string]interface{}{"v": v})
+	if err != nil {
+		return nil, err
+	}
+	document := Document{}
+	if err := bson.UnmarshalExtJSON(data, false, &document); err != nil {
+		return nil, err
+	}
+	return document["v"], nil
+}
+
+func (o Operators) add(operator string, path string, value interface{}) {
+	changes, _ := o[operator].(Document)
+	if changes == nil {
+		changes = Document{}
+		o[operator] = changes
+	}
+	changes[path] = value
+}
+
+func mergeOperators(operators Operators, prefix string, patch map[string]interface{}) error {
+	for key, value := range patch {
+		path := prefix + key
+		if value == nil {
+			operators.add("$unset", path, "")
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 && !isExtendedJSON(nested) {
+			if err := mergeOperators(operators, path+".", nested); err != nil {
+				return err
+			}
+			continue
+		}
+		converted, err := fromJSON(value)
+		if err != nil {
+			return err
+		}
+		operators.add("$set", path, converted)
+	}
+	return nil
+}
+
+func isExtendedJSON(v map[string]interface{}) bool {
+	for key := range v {
+		if strings.HasPrefix(key, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+// MergePatch translates an RFC 7386 JSON Merge Patch into update operators.
+func MergePatch(body []byte) (Operators, error) {
+	patch := map[string]interface{}{}
+	if err := decodeJSON(body, &patch); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %w", err)
+	}
+	operators := Operators{}
+	if err := mergeOperators(operators, "", patch); err != nil {
+		return nil, err
+	}
+	return operators, nil
+}
+
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from"`
+	Value interface{} `json:"value"`
+}
+
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func arrayIndex(token string, length int, appending bool) (int, error) {
+	if appending && token == "-" {
+		return length, nil
+	}
+	index, err := strconv.Atoi(token)
+	limit := length
+	if !appending {
+		limit = length - 1
+	}
+	if err != nil || index < 0 || index > limit {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	return index, nil
+}
+
+func getPointer(document interface{}, tokens []string) (interface{}, error) {
+	current := document
+	for _, token := range tokens {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path %q not found", token)
+			}
+			current = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			current = node[index]
+		default:
+			return nil, fmt.Errorf("path %q not found", token)
+		}
+	}
+	return current, nil
+}
+
+func updatePointer(document interface{}, tokens []string, change func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("cannot change the root document")
+	}
+	if len(tokens) == 1 {
+		return change(document, tokens[0])
+	}
+	switch node := document.(type) {
+	case map[string]interface{}:
+		child, ok := node[tokens[0]]
+		if !ok {
+			return nil, fmt.Errorf("path %q not found", tokens[0])
+		}
+		updated, err := updatePointer(child, tokens[1:], change)
+		if err != nil {
+			return nil, err
+		}
+		node[tokens[0]] = updated
+		return node, nil
+	case []interface{}:
+		index, err := arrayIndex(tokens[0], len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := updatePointer(node[index], tokens[1:], change)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = updated
+		return node, nil
+	}
+	return nil, fmt.Errorf("path %q not found", tokens[0])
+}
+
+func addPointer(document interface{}, tokens []string, value interface{}) (interface{}, error) {
+	return updatePointer(document, tokens, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			index, err := arrayIndex(token, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[index+1:], node[index:])
+			node[index] = value
+			return node, nil
+		}
+		return nil, fmt.Errorf("path %q not found", token)
+	})
+}
+
+func removePointer(document interface{}, tokens []string) (interface{}, error) {
+	return updatePointer(document, tokens, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := node[token]; !ok {
+				return nil, fmt.Errorf("path %q not found", token)
+			}
+			delete(node, token)
+			return node, nil
+		case []interface{}:
+			index, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(node[:index], node[index+1:]...), nil
+		}
+		return nil, fmt.Errorf("path %q not found", token)
+	})
+}
+
+func applyOperation(document interface{}, operation patchOperation) (interface{}, error) {
+	tokens, err := parsePointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch operation.Op {
+	case "add":
+		return addPointer(document, tokens, operation.Value)
+	case "remove":
+		return removePointer(document, tokens)
+	case "replace":
+		if _, err := getPointer(document, tokens); err != nil {
+			return nil, err
+		}
+		document, err = removePointer(document, tokens)
+		if err != nil {
+			return nil, err
+		}
+		return addPointer(document, tokens, operation.Value)
+	case "move", "copy":
+		from, err := parsePointer(operation.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := getPointer(document, from)
+		if err != nil {
+			return nil, err
+		}
+		if operation.Op == "move" {
+			document, err = removePointer(document, from)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			value, err = cloneJSON(value)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return addPointer(document, tokens, value)
+	case "test":
+		value, err := getPointer(document, tokens)
+		if err != nil {
+			return nil, err
+		}
+		expected, err := json.Marshal(operation.Value)
+		if err != nil {
+			return nil, err
+		}
+		actual, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(expected, actual) {
+			return nil, fmt.Errorf("test failed for path %q", operation.Path)
+		}
+		return document, nil
+	}
+	return nil, fmt.Errorf("unsupported patch operation %q", operation.Op)
+}
+
+func cloneJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var clone interface{}
+	err = decodeJSON(data, &clone)
+	return clone, err
+}
+
+// JSONPatch returns the update operators of an RFC 6902 JSON Patch of current.
+func JSONPatch(current Document, body []byte) (Operators, error) {
+	operations := []patchOperation{}
+	if err := decodeJSON(body, &operations); err != nil {
+		return nil, fmt.Errorf("invalid json patch: %w", err)
+	}
+	data, err := bson.MarshalExtJSON(current, false, false)
+	if err != nil {
+		return nil, err
+	}
+	var document interface{}
+	if err := decodeJSON(data, &document); err != nil {
+		return nil, err
+	}
+	for _, operation := range operations {
+		document, err = applyOperation(document, operation)
+		if err != nil {
+			return nil, err
+		}
+	}
+	patched, ok := document.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("the patched document must be an object")
+	}
+
+	operators := Operators{}
+	for key, value := range patched {
+		converted, err := fromJSON(value)
+		if err != nil {
+			return nil, err
+		}
+		if existing, ok := current[key]; !ok || !valuesEqual(existing, converted) {
+			operators.add("$set", key, converted)
+		}
+	}
+	for key := range current {
+		if _, ok := patched[key]; !ok {
+			operators.add("$unset", key, "")
+		}
+	}
+	return operators, nil
+}
@@ -0,0 +1,208 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMergePatch(t *testing.T) {
+	oid := primitive.NewObjectID()
+	operators, err := MergePatch([]byte(`{"name":"Ada","address":{"city":"London","zip":null},"owner":{"$oid":"` + oid.Hex() + `"},"note":null}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := Document{"name": "Ada", "address.city": "London", "owner": oid}
+	if !reflect.DeepEqual(operators["$set"], set) {
+		t.Errorf("$set is %v", operators["$set"])
+	}
+	unset := Document{"address.zip": "", "note": ""}
+	if !reflect.DeepEqual(operators["$unset"], unset) {
+		t.Errorf("$unset is %v", operators["$unset"])
+	}
+	if len(operators) != 2 {
+		t.Errorf("operators are %v", operators)
+	}
+
+	if _, err := MergePatch([]byte(`["name"]`)); err == nil {
+		t.Error("merge patch of an array succeeded")
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	current := Document{
+		"_id":   primitive.NewObjectID(),
+		"name":  "Ada",
+		"tags":  primitive.A{"a"},
+		"count": int64(3),
+		"old":   true,
+	}
+	operators, err := JSONPatch(current, []byte(`[
+		{"op":"test","path":"/name","value":"Ada"},
+		{"op":"replace","path":"/name","value":"Grace"},
+		{"op":"add","path":"/tags/-","value":"b"},
+		{"op":"remove","path":"/old"},
+		{"op":"copy","from":"/name","path":"/alias"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := Document{"name": "Grace", "tags": primitive.A{"a", "b"}, "alias": "Grace"}
+	if !reflect.DeepEqual(operators["$set"], set) {
+		t.Errorf("$set is %v", operators["$set"])
+	}
+	if !reflect.DeepEqual(operators["$unset"], Document{"old": ""}) {
+		t.Errorf("$unset is %v", operators["$unset"])
+	}
+
+	failures := map[string]string{
+		"failed test":      `[{"op":"test","path":"/name","value":"Grace"}]`,
+		"missing path":     `[{"op":"replace","path":"/missing","value":1}]`,
+		"unsupported op":   `[{"op":"merge","path":"/name","value":1}]`,
+		"invalid pointer":  `[{"op":"add","path":"name","value":1}]`,
+		"not an object":    `[{"op":"replace","path":"","value":[1]}]`,
+		"not a json patch": `{"op":"remove","path":"/name"}`,
+	}
+	for name, patch := range failures {
+		if operators, err := JSONPatch(current, []byte(patch)); err == nil {
+			t.Errorf("%s: patch returned %v", name, operators)
+		}
+	}
+	if current["name"] != "Ada" || current["old"] != true {
+		t.Errorf("patch changed the current document %v", current)
+	}
+}
+
+func TestOperatorsValidate(t *testing.T) {
+	valid := Operators{"$set": Document{"name": "Ada", "address.city": "London"}, "$inc": Document{"count": 1}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("valid operators failed with %v", err)
+	}
+	invalid := map[string]Operators{
+		"empty":          {},
+		"unsupported":    {"$rename": Document{"name": "alias"}},
+		"not a document": {"$set": 1},
+		"operator field": {"$set": Document{"$where": "1"}},
+		"empty field":    {"$unset": Document{"": ""}},
+	}
+	for name, operators := range invalid {
+		if err := operators.Validate(); err == nil {
+			t.Errorf("%s operators passed", name)
+		}
+	}
+}
+
+// racingAdapter runs race once right after the next document read.
+type racingAdapter struct {
+	*MemoryAdapter
+	race func()
+}
+
+func (a *racingAdapter) FindOne(ctx context.Context, filter interface{}, opts GetHandlerOptions) *mongo.SingleResult {
+	result := a.MemoryAdapter.FindOne(ctx, filter, opts)
+	if race := a.race; race != nil {
+		a.race = nil
+		race()
+	}
+	return result
+}
+
+func newAccounts(t *testing.T) (*Service, *racingAdapter, *fiber.App, string) {
+	t.Helper()
+	adapter := &racingAdapter{MemoryAdapter: NewMemoryAdapter()}
+	accounts := newService("accounts", account{}).
+		SetEntity(Entity{Ctx: context.Background(), Adapter: adapter}).
+		SetVersioning().
+		SetResource(CRUD[account, account]{})
+	engine := serve(InitApp(), accounts)
+	item, err := Typed[account](accounts.Handler).Create(Document{"name": "Ada", "email": "ada@example.com", "secret": "s", "role": "user", "tags": primitive.A{}}, options.InsertOne())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return accounts, adapter, engine, item.ID.Hex()
+}
+
+func jsonPatch(t *testing.T, engine *fiber.App, id string, patch string, headers ...string) (int, map[string]interface{}) {
+	t.Helper()
+	headers = append([]string{"Content-Type", JSONPatchType, "X-User", "user"}, headers...)
+	status, _, out := send(t, engine, "PATCH", "/accounts/"+id, []byte(patch), headers...)
+	document, _ := out.(map[string]interface{})
+	return status, document
+}
+
+func TestJSONPatchVersion(t *testing.T) {
+	accounts, adapter, engine, id := newAccounts(t)
+
+	status, out := jsonPatch(t, engine, id, `[{"op":"replace","path":"/name","value":"Grace"}]`)
+	if status != fiber.StatusOK || out["name"] != "Grace" || out["version"] != float64(2) {
+		t.Fatalf("json patch returned %d %v", status, out)
+	}
+
+	adapter.race = func() {
+		oid, _ := primitive.ObjectIDFromHex(id)
+		if _, err := Typed[account](accounts.Handler).Patch(Document{"_id": oid}, Document{"name": "Linus"}, options.FindOneAndUpdate()); err != nil {
+			t.Error(err)
+		}
+	}
+	status, out = jsonPatch(t, engine, id, `[{"op":"test","path":"/name","value":"Grace"},{"op":"replace","path":"/name","value":"Ken"}]`)
+	if status != fiber.StatusConflict {
+		t.Fatalf("json patch of a concurrently changed document returned %d %v", status, out)
+	}
+
+	status, out = jsonPatch(t, engine, id, `[{"op":"replace","path":"/name","value":"Ken"}]`, "If-Match", `"2"`)
+	if status != fiber.StatusPreconditionFailed {
+		t.Fatalf("json patch with a stale If-Match returned %d %v", status, out)
+	}
+	status, out = jsonPatch(t, engine, id, `[{"op":"replace","path":"/name","value":"Ken"}]`, "If-Match", `"3"`)
+	if status != fiber.StatusOK || out["name"] != "Ken" || out["version"] != float64(4) {
+		t.Fatalf("json patch with a current If-Match returned %d %v", status, out)
+	}
+
+	unversioned := newService("records", record{}).SetResource(CRUD[record, record]{})
+	engine = serve(InitApp(), unversioned)
+	item, err := Typed[record](unversioned.Handler).Create(Document{"name": "record"}, options.InsertOne())
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, _, _ = send(t, engine, "PATCH", "/records/"+item.ID.Hex(), []byte(`[{"op":"replace","path":"/name","value":"x"}]`), "Content-Type", JSONPatchType, "X-User", "user")
+	if status != fiber.StatusBadRequest {
+		t.Fatalf("json patch of an unversioned service returned %d", status)
+	}
+}
+
+func TestJSONPatchAccess(t *testing.T) {
+	accounts, _, engine, id := newAccounts(t)
+
+	forbidden := map[string]string{
+		"readonly":      `[{"op":"replace","path":"/_id","value":"x"}]`,
+		"writeonce":     `[{"op":"replace","path":"/email","value":"x@example.com"}]`,
+		"hidden test":   `[{"op":"test","path":"/secret","value":"s"}]`,
+		"hidden copy":   `[{"op":"copy","from":"/secret","path":"/name"}]`,
+		"hidden move":   `[{"op":"move","from":"/secret","path":"/name"}]`,
+		"admin":         `[{"op":"replace","path":"/role","value":"admin"}]`,
+		"whole":         `[{"op":"replace","path":"","value":{"name":"x"}}]`,
+		"after allowed": `[{"op":"replace","path":"/name","value":"x"},{"op":"remove","path":"/version"}]`,
+	}
+	for name, patch := range forbidden {
+		if status, out := jsonPatch(t, engine, id, patch); status != fiber.StatusForbidden {
+			t.Errorf("%s: json patch returned %d %v", name, status, out)
+		}
+	}
+	stored, err := Typed[account](accounts.Handler).Get(Document{}, options.FindOne())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != "Ada" || stored.Role != "user" || stored.Version != 1 {
+		t.Fatalf("forbidden json patches changed the document %+v", stored)
+	}
+
+	status, out := jsonPatch(t, engine, id, `[{"op":"replace","path":"/role","value":"admin"}]`, "X-Role", AdminRole)
+	if status != fiber.StatusOK || out["role"] != "admin" {
+		t.Fatalf("json patch of an admin field by an admin returned %d %v", status, out)
+	}
+}
@@ -0,0 +1,399 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// QueryOperators are the filter operators of query strings, as in ?age[$gt]=18.
+var QueryOperators = map[string]bool{
+	"$eq":     true,
+	"$ne":     true,
+	"$gt":     true,
+	"$gte":    true,
+	"$lt":     true,
+	"$lte":    true,
+	"$in":     true,
+	"$nin":    true,
+	"$exists": true,
+}
+
+var (
+	ErrNotQueryable = errors.New("field is not queryable")
+	ErrNotSortable  = errors.New("field is not sortable")
+)
+
+// Query is a parsed query string. Limit and Skip are nil when unset.
+type Query struct {
+	Filter bson.M
+	Sort   bson.D
+	Select bson.M
+	Limit  *int64
+	Skip   *int64
+	Cursor string
+
+	// Archived asks for archived documents too, see Handler.WithArchived.
+	Archived bool
+}
+
+func (q Query) FindOptions() *options.FindOptions {
+	opts := options.Find()
+	if len(q.Sort) > 0 {
+		opts.SetSort(q.Sort)
+	}
+	if len(q.Select) > 0 {
+		opts.SetProjection(q.Select)
+	}
+	if q.Limit != nil {
+		opts.SetLimit(*q.Limit)
+	}
+	if q.Skip != nil {
+		opts.SetSkip(*q.Skip)
+	}
+	return opts
+}
+
+// Allow checks the fields of a query against the queryable and sortable ones.
+func (q Query) Allow(queryable map[string]bool, sortable map[string]bool) error {
+	if err := allowFilter(q.Filter, queryable); err != nil {
+		return err
+	}
+	for field := range q.Select {
+		if !allowedField(field, queryable) {
+			return fmt.Errorf("%w: %s", ErrNotQueryable, field)
+		}
+	}
+	for _, e := range q.Sort {
+		if !allowedField(e.Key, sortable) {
+			return fmt.Errorf("%w: %s", ErrNotSortable, e.Key)
+		}
+	}
+	return nil
+}
+
+func allowFilter(filter bson.M, queryable map[string]bool) error {
+	for field, value := range filter {
+		if field == "$or" || field == "$and" {
+			clauses, _ := value.(primitive.A)
+			for _, clause := range clauses {
+				if err := allowFilter(clause.(bson.M), queryable); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if !allowedField(field, queryable) {
+			return fmt.Errorf("%w: %s", ErrNotQueryable, field)
+		}
+	}
+	return nil
+}
+
+func allowedField(field string, allowed map[string]bool) bool {
+	for {
+		if allowed[field] {
+			return true
+		}
+		i := strings.LastIndexByte(field, '.')
+		if i < 0 {
+			return false
+		}
+		field = field[:i]
+	}
+}
+
+type queryParser struct {
+	kinds map[string]columnKind
+	query Query
+}
+
+// ParseQuery parses a raw query string, typing values by the fields of schema.
+func ParseQuery(raw string, schema interface{}) (Query, error) {
+	p := &queryParser{
+		kinds: make(map[string]columnKind),
+		query: Query{Filter: bson.M{}, Sort: bson.D{}, Select: bson.M{}},
+	}
+	if schema != nil {
+		for _, column := range schemaColumns(schema) {
+			p.kinds[column.Name] = column.Kind
+		}
+	}
+	for _, pair := range strings.Split(raw, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(key)
+		if err != nil {
+			return Query{}, fmt.Errorf("invalid query key %q", key)
+		}
+		value, err = url.QueryUnescape(value)
+		if err != nil {
+			return Query{}, fmt.Errorf("invalid query value for %s", key)
+		}
+		tokens, err := splitQueryKey(key)
+		if err != nil {
+			return Query{}, err
+		}
+		if err := p.parse(tokens, value); err != nil {
+			return Query{}, err
+		}
+	}
+	filter, err := finalizeFilter(p.query.Filter)
+	if err != nil {
+		return Query{}, err
+	}
+	p.query.Filter = filter
+	return p.query, nil
+}
+
+func splitQueryKey(key string) ([]string, error) {
+	i := strings.IndexByte(key, '[')
+	if i < 0 {
+		return []string{key}, nil
+	}
+	tokens := []string{key[:i]}
+	rest := key[i:]
+	for len(rest) > 0 {
+		j := strings.IndexByte(rest, ']')
+		if rest[0] != '[' || j < 0 {
+			return nil, fmt.Errorf("invalid query key %q", key)
+		}
+		tokens = append(tokens, rest[1:j])
+		rest = rest[j+1:]
+	}
+	return tokens, nil
+}
+
+func parseCount(name string, value string) (*int64, error) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("%s must be a non-negative integer", name)
+	}
+	return &n, nil
+}
+
+func paging(params map[string]string) (limit *int64, skip *int64, err error) {
+	for _, key := range []string{"$limit", "limit", "$skip", "skip"} {
+		value, ok := params[key]
+		if !ok {
+			continue
+		}
+		delete(params, key)
+		if strings.HasSuffix(key, "limit") {
+			limit, err = parseCount("$limit", value)
+		} else {
+			skip, err = parseCount("$skip", value)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return limit, skip, nil
+}
+
+func (p *queryParser) parse(tokens []string, value string) error {
+	switch tokens[0] {
+	case "$limit", "limit":
+		limit, err := parseCount("$limit", value)
+		p.query.Limit = limit
+		return err
+	case "$skip", "skip":
+		skip, err := parseCount("$skip", value)
+		p.query.Skip = skip
+		return err
+	case "$archived":
+		archived, err := strconv.ParseBool(value)
+		if err != nil || len(tokens) > 1 {
+			return fmt.Errorf("$archived must be a boolean")
+		}
+		p.query.Archived = archived
+		return nil
+	case "$cursor":
+		if len(tokens) > 1 || value == "" {
+			return fmt.Errorf("invalid $cursor")
+		}
+		p.query.Cursor = value
+		return nil
+	case "$sort":
+		if len(tokens) != 2 || tokens[1] == "" {
+			return fmt.Errorf("$sort needs a field, as in $sort[field]=1")
+		}
+		direction, err := strconv.Atoi(value)
+		if err != nil || (direction != 1 && direction != -1) {
+			return fmt.Errorf("$sort[%s] must be 1 or -1", tokens[1])
+		}
+		p.query.Sort = append(p.query.Sort, bson.E{Key: tokens[1], Value: direction})
+		return nil
+	case "$select":
+		if len(tokens) > 2 {
+			return fmt.Errorf("invalid $select")
+		}
+		for _, field := range strings.Split(value, ",") {
+			if field == "" || strings.HasPrefix(field, "$") {
+				return fmt.Errorf("invalid $select field %q", field)
+			}
+			p.query.Select[field] = 1
+		}
+		return nil
+	}
+	return p.condition(p.query.Filter, tokens, value)
+}
+
+// condition keys $or and $and clauses by index until finalizeFilter orders them.
+func (p *queryParser) condition(filter bson.M, tokens []string, value string) error {
+	field := tokens[0]
+	if field == "$or" || field == "$and" {
+		if len(tokens) < 3 {
+			return fmt.Errorf("%s needs an index and a field, as in %s[0][field]=value", field, field)
+		}
+		index, err := strconv.Atoi(tokens[1])
+		if err != nil || index < 0 {
+			return fmt.Errorf("invalid %s index %q", field, tokens[1])
+		}
+		clauses, _ := filter[field].(map[int]bson.M)
+		if clauses == nil {
+			clauses = make(map[int]bson.M)
+			filter[field] = clauses
+		}
+		if clauses[index] == nil {
+			clauses[index] = bson.M{}
+		}
+		return p.condition(clauses[index], tokens[2:], value)
+	}
+	if field == "" || strings.HasPrefix(field, "$") {
+		return fmt.Errorf("unsupported query parameter %q", field)
+	}
+
+	if len(tokens) == 1 {
+		converted, err := p.convert(field, value)
+		if err != nil {
+			return err
+		}
+		switch current := filter[field].(type) {
+		case nil:
+			filter[field] = converted
+		case bson.M:
+			return fmt.Errorf("conflicting conditions for %s", field)
+		default:
+			filter[field] = bson.M{"$in": primitive.A{current, converted}}
+		}
+		return nil
+	}
+
+	operator := tokens[1]
+	if !QueryOperators[operator] {
+		return fmt.Errorf("unsupported query operator %s", operator)
+	}
+	operators, ok := filter[field].(bson.M)
+	if !ok {
+		if filter[field] != nil {
+			return fmt.Errorf("conflicting conditions for %s", field)
+		}
+		operators = bson.M{}
+		filter[field] = operators
+	}
+	switch operator {
+	case "$in", "$nin":
+		if len(tokens) > 3 {
+			return fmt.Errorf("invalid %s for %s", operator, field)
+		}
+		converted, err := p.convert(field, value)
+		if err != nil {
+			return err
+		}
+		items, _ := operators[operator].(primitive.A)
+		operators[operator] = append(items, converted)
+	case "$exists":
+		if len(tokens) > 2 {
+			return fmt.Errorf("invalid %s for %s", operator, field)
+		}
+		exists, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s[$exists] must be a boolean", field)
+		}
+		operators[operator] = exists
+	default:
+		if len(tokens) > 2 {
+			return fmt.Errorf("invalid %s for %s", operator, field)
+		}
+		converted, err := p.convert(field, value)
+		if err != nil {
+			return err
+		}
+		operators[operator] = converted
+	}
+	return nil
+}
+
+func (p *queryParser) convert(field string, value string) (interface{}, error) {
+	kind, ok := p.kinds[field]
+	if !ok {
+		return value, nil
+	}
+	switch kind {
+	case idColumn:
+		if id, err := primitive.ObjectIDFromHex(value); err == nil {
+			return id, nil
+		}
+		return value, nil
+	case boolColumn:
+		converted, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a boolean", field)
+		}
+		return converted, nil
+	case intColumn:
+		converted, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be an integer", field)
+		}
+		return converted, nil
+	case floatColumn:
+		converted, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number", field)
+		}
+		return converted, nil
+	case timeColumn:
+		converted, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be an RFC 3339 time", field)
+		}
+		return converted, nil
+	}
+	return value, nil
+}
+
+func finalizeFilter(filter bson.M) (bson.M, error) {
+	for _, key := range []string{"$or", "$and"} {
+		clauses, ok := filter[key].(map[int]bson.M)
+		if !ok {
+			continue
+		}
+		indexes := make([]int, 0, len(clauses))
+		for index := range clauses {
+			indexes = append(indexes, index)
+		}
+		sort.Ints(indexes)
+		items := primitive.A{}
+		for _, index := range indexes {
+			clause, err := finalizeFilter(clauses[index])
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, clause)
+		}
+		filter[key] = items
+	}
+	return filter, nil
+}
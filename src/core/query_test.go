@@ -0,0 +1,103 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type person struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	Name     string             `bson:"name"`
+	Age      int64              `bson:"age"`
+	Score    float64            `bson:"score"`
+	Verified bool               `bson:"verified"`
+	Born     time.Time          `bson:"born"`
+}
+
+func TestParseQuery(t *testing.T) {
+	oid := primitive.NewObjectID()
+	raw := "_id=" + oid.Hex() +
+		"&age[$gt]=18&age[$lte]=65" +
+		"&name[$in]=Ada&name[$in]=Grace" +
+		"&verified=true&born[$lt]=2000-01-01T00:00:00Z" +
+		"&$or[1][score]=1.5&$or[0][name]=Linus" +
+		"&$sort[age]=-1&$sort[name]=1&$select=name,age&$limit=10&$skip=20"
+	query, err := ParseQuery(raw, person{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := bson.M{
+		"_id":      oid,
+		"age":      bson.M{"$gt": int64(18), "$lte": int64(65)},
+		"name":     bson.M{"$in": primitive.A{"Ada", "Grace"}},
+		"verified": true,
+		"born":     bson.M{"$lt": time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+		"$or":      primitive.A{bson.M{"name": "Linus"}, bson.M{"score": 1.5}},
+	}
+	if !reflect.DeepEqual(query.Filter, filter) {
+		t.Errorf("filter is %v", query.Filter)
+	}
+	if sort := (bson.D{{Key: "age", Value: -1}, {Key: "name", Value: 1}}); !reflect.DeepEqual(query.Sort, sort) {
+		t.Errorf("sort is %v", query.Sort)
+	}
+	if !reflect.DeepEqual(query.Select, bson.M{"name": 1, "age": 1}) {
+		t.Errorf("select is %v", query.Select)
+	}
+	if query.Limit == nil || *query.Limit != 10 || query.Skip == nil || *query.Skip != 20 {
+		t.Errorf("limit and skip are %v %v", query.Limit, query.Skip)
+	}
+
+	query, err = ParseQuery("name=Ada&name=Grace", person{})
+	if err != nil || !reflect.DeepEqual(query.Filter, bson.M{"name": bson.M{"$in": primitive.A{"Ada", "Grace"}}}) {
+		t.Errorf("repeated values parsed to %v %v", query.Filter, err)
+	}
+
+	invalid := map[string]string{
+		"integer":           "age=old",
+		"boolean":           "verified=maybe",
+		"time":              "born=yesterday",
+		"operator":          "age[$where]=1",
+		"operator field":    "$where=1",
+		"sort direction":    "$sort[age]=2",
+		"sort field":        "$sort=1",
+		"select operator":   "$select=$where",
+		"negative limit":    "$limit=-1",
+		"or without field":  "$or[0]=1",
+		"conflicting value": "age=1&age[$gt]=2",
+		"bracket":           "age[$gt=1",
+	}
+	for name, raw := range invalid {
+		if query, err := ParseQuery(raw, person{}); err == nil {
+			t.Errorf("%s: %s parsed to %+v", name, raw, query)
+		}
+	}
+}
+
+func TestQueryAllow(t *testing.T) {
+	queryable := map[string]bool{"name": true, "address": true}
+	sortable := map[string]bool{"name": true}
+	allowed := []string{"name=Ada", "address.city=London", "$or[0][name]=Ada&$or[1][address.zip]=1", "$select=name,address.city", "$sort[name]=1"}
+	for _, raw := range allowed {
+		query, err := ParseQuery(raw, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := query.Allow(queryable, sortable); err != nil {
+			t.Errorf("%s failed with %v", raw, err)
+		}
+	}
+	rejected := []string{"password=x", "password[$exists]=true", "$or[0][name]=Ada&$or[1][reset_token]=x", "$and[0][$or][0][password]=x", "$select=name,password", "$sort[address]=1", "names=Ada"}
+	for _, raw := range rejected {
+		query, err := ParseQuery(raw, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := query.Allow(queryable, sortable); err == nil {
+			t.Errorf("%s was allowed", raw)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// JSONSchemaDialect is the JSON Schema version of the generated schemas.
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// SchemasPath is where the service schemas are served, under APIPrefix.
+const SchemasPath = "/schemas"
+
+// JSONSchema is a JSON Schema document.
+type JSONSchema = map[string]interface{}
+
+// ServiceSchemas are the request and response schemas of a service by method.
+type ServiceSchemas struct {
+	Requests  map[string]JSONSchema `json:"requests,omitempty"`
+	Responses map[string]JSONSchema `json:"responses,omitempty"`
+}
+
+// SchemaValidator is implemented by adapters enforcing a schema on a collection.
+type SchemaValidator interface {
+	SetValidator(ctx context.Context, schema bson.M) error
+}
+
+var enumType = reflect.TypeOf((*Enum)(nil)).Elem()
+
+// BSON schemas use the bson names and accept null for optional fields.
+type schemaGenerator struct {
+	bson    bool
+	walking map[reflect.Type]bool
+}
+
+func schemaType(schema interface{}) reflect.Type {
+	t := reflect.TypeOf(schema)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// JSONSchemaOf generates the JSON Schema of a schema struct.
+func JSONSchemaOf(schema interface{}) JSONSchema {
+	t := schemaType(schema)
+	if t == nil {
+		return JSONSchema{"$schema": JSONSchemaDialect}
+	}
+	g := schemaGenerator{walking: map[reflect.Type]bool{}}
+	document := g.of(t)
+	document["$schema"] = JSONSchemaDialect
+	document["title"] = t.Name()
+	return document
+}
+
+// BSONSchemaOf generates the mongo $jsonSchema of a schema struct.
+func BSONSchemaOf(schema interface{}) bson.M {
+	t := schemaType(schema)
+	if t == nil {
+		return bson.M{}
+	}
+	g := schemaGenerator{bson: true, walking: map[reflect.Type]bool{}}
+	document := bson.M(g.of(t))
+	document["title"] = t.Name()
+	return document
+}
+
+func (g schemaGenerator) typed(jsonType string, bsonType interface{}) map[string]interface{} {
+	if g.bson {
+		return map[string]interface{}{"bsonType": bsonType}
+	}
+	return map[string]interface{}{"type": jsonType}
+}
+
+func (g schemaGenerator) of(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		if g.bson {
+			return map[string]interface{}{"bsonType": "date"}
+		}
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case objectIDType:
+		if g.bson {
+			return map[string]interface{}{"bsonType": "objectId"}
+		}
+		return map[string]interface{}{"type": "string", "pattern": "^[0-9a-fA-F]{24}$"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return g.typed("string", "string")
+	case reflect.Bool:
+		return g.typed("boolean", "bool")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return g.typed("integer", []string{"int", "long"})
+	case reflect.Float32, reflect.Float64:
+		return g.typed("number", "number")
+	case reflect.Slice, reflect.Array:
+		schema := g.typed("array", "array")
+		if items := g.of(t.Elem()); len(items) > 0 {
+			schema["items"] = items
+		}
+		return schema
+	case reflect.Map:
+		schema := g.typed("object", "object")
+		if values := g.of(t.Elem()); len(values) > 0 {
+			schema["additionalProperties"] = values
+		}
+		return schema
+	case reflect.Struct:
+		if g.walking[t] {
+			return g.typed("object", "object")
+		}
+		g.walking[t] = true
+		defer delete(g.walking, t)
+		return g.object(t)
+	}
+	return map[string]interface{}{}
+}
+
+func (g schemaGenerator) object(t reflect.Type) map[string]interface{} {
+	schema := g.typed("object", "object")
+	properties := map[string]interface{}{}
+	required := []string{}
+	nameTag := "json"
+	if g.bson {
+		nameTag = "bson"
+	}
+	access := AccessOf(reflect.New(t).Interface())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get(nameTag), ",")[0]
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+			if g.bson {
+				name = strings.ToLower(name)
+			}
+		}
+		property := g.of(field.Type)
+		rules := strings.Split(field.Tag.Get(BindingTag), ",")
+		isRequired := g.rules(property, field.Type, rules)
+		if isRequired {
+			required = append(required, name)
+		}
+		if g.bson {
+			if !isRequired {
+				nullable(property)
+			}
+		} else {
+			if access[name].ReadOnly {
+				property["readOnly"] = true
+			}
+			if access[name].Hidden {
+				property["writeOnly"] = true
+			}
+		}
+		properties[name] = property
+	}
+	schema["properties"] = properties
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+func nullable(property map[string]interface{}) {
+	switch bsonType := property["bsonType"].(type) {
+	case string:
+		property["bsonType"] = []string{bsonType, "null"}
+	case []string:
+		property["bsonType"] = append(append([]string{}, bsonType...), "null")
+	}
+	if values, ok := property["enum"].([]string); ok {
+		enum := make([]interface{}, 0, len(values)+1)
+		for _, value := range values {
+			enum = append(enum, value)
+		}
+		property["enum"] = append(enum, nil)
+	}
+}
+
+func (g schemaGenerator) rules(property map[string]interface{}, t reflect.Type, rules []string) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	required := false
+	for _, rule := range rules {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "dive" {
+			break
+		}
+		switch name {
+		case "required":
+			required = true
+		case "email":
+			if !g.bson {
+				property["format"] = "email"
+			}
+		case "enum":
+			if t.Implements(enumType) {
+				property["enum"] = reflect.Zero(t).Interface().(Enum).Values()
+			}
+		case "oneof":
+			property["enum"] = strings.Fields(param)
+		case "min", "max", "len", "gte", "lte":
+			bound, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			for _, keyword := range boundKeywords(name, t.Kind()) {
+				property[keyword] = bound
+			}
+		}
+	}
+	return required
+}
+
+func boundKeywords(rule string, kind reflect.Kind) []string {
+	lower := rule == "min" || rule == "gte" || rule == "len"
+	upper := rule == "max" || rule == "lte" || rule == "len"
+	keywords := []string{}
+	switch kind {
+	case reflect.String:
+		if lower {
+			keywords = append(keywords, "minLength")
+		}
+		if upper {
+			keywords = append(keywords, "maxLength")
+		}
+	case reflect.Slice, reflect.Array:
+		if lower {
+			keywords = append(keywords, "minItems")
+		}
+		if upper {
+			keywords = append(keywords, "maxItems")
+		}
+	case reflect.Map:
+		if lower {
+			keywords = append(keywords, "minProperties")
+		}
+		if upper {
+			keywords = append(keywords, "maxProperties")
+		}
+	default:
+		if lower {
+			keywords = append(keywords, "minimum")
+		}
+		if upper {
+			keywords = append(keywords, "maximum")
+		}
+	}
+	return keywords
+}
+
+// SetResponseSchema sets the schema of the responses of a method.
+func (s *Service) SetResponseSchema(method string, schema interface{}) *Service {
+	if s.Responses == nil {
+		s.Responses = make(map[string]interface{})
+	}
+	s.Responses[method] = schema
+	return s
+}
+
+// SetStorageValidation makes InstallValidator install the service schema.
+func (s *Service) SetStorageValidation() *Service {
+	s.StorageValidation = true
+	return s
+}
+
+// InstallValidator installs the BSON schema of the service on SchemaValidator
+// adapters.
+func (s *Service) InstallValidator() error {
+	if !s.StorageValidation || s.Schema == nil {
+		return nil
+	}
+	validator, ok := s.Entity.Adapter.(SchemaValidator)
+	if !ok {
+		return nil
+	}
+	ctx := s.Entity.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return validator.SetValidator(ctx, BSONSchemaOf(s.Schema))
+}
+
+// Schemas generates the request and response schemas of the service, without
+// the fields its access rules hide.
+func (s *Service) Schemas() ServiceSchemas {
+	schemas := ServiceSchemas{
+		Requests:  make(map[string]JSONSchema, len(s.Requests)),
+		Responses: make(map[string]JSONSchema, len(s.Responses)),
+	}
+	for method, schema := range s.Requests {
+		partial, isPartial := schema.(partialSchema)
+		if isPartial {
+			schema = partial.schema
+		}
+		document := JSONSchemaOf(schema)
+		if isPartial {
+			delete(document, "required")
+		}
+		create := method == MethodCreate
+		restrict(document, func(field string) bool {
+			return s.Access.Writable(field, create, true)
+		})
+		if s.Multi[method] && method == MethodCreate {
+			item := JSONSchema{}
+			for key, value := range document {
+				if key != "$schema" {
+					item[key] = value
+				}
+			}
+			document = JSONSchema{
+				"$schema": JSONSchemaDialect,
+				"title":   document["title"],
+				"oneOf":   []interface{}{item, JSONSchema{"type": "array", "items": item}},
+			}
+		}
+		schemas.Requests[method] = document
+	}
+	for method, schema := range s.Responses {
+		document := JSONSchemaOf(schema)
+		restrict(document, func(field string) bool {
+			return s.Access.Visible(field, true)
+		})
+		schemas.Responses[method] = document
+	}
+	return schemas
+}
+
+func restrict(document JSONSchema, allowed func(field string) bool) {
+	properties, _ := document["properties"].(map[string]interface{})
+	for field := range properties {
+		if !allowed(field) {
+			delete(properties, field)
+		}
+	}
+	required, _ := document["required"].([]string)
+	kept := required[:0]
+	for _, field := range required {
+		if allowed(field) {
+			kept = append(kept, field)
+		}
+	}
+	if len(kept) == 0 {
+		delete(document, "required")
+	} else if required != nil {
+		document["required"] = kept
+	}
+}
+
+// SchemasController serves the schemas of every service, or of the one named
+// by the service route param.
+func SchemasController(services map[string]*Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if name := c.Params("service"); name != "" {
+			service, ok := services[name]
+			if !ok {
+				return NotFound("service not found")
+			}
+			return c.JSON(service.Schemas())
+		}
+		schemas := make(map[string]ServiceSchemas, len(services))
+		for name, service := range services {
+			schemas[name] = service.Schemas()
+		}
+		return c.JSON(schemas)
+	}
+}
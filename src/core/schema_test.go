@@ -0,0 +1,172 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type node struct {
+	Name     string `json:"name" bson:"name" binding:"required"`
+	Children []node `json:"children" bson:"children"`
+	Parent   *node  `json:"parent" bson:"parent"`
+}
+
+type validatingAdapter struct {
+	*MemoryAdapter
+	schema bson.M
+}
+
+func (a *validatingAdapter) SetValidator(ctx context.Context, schema bson.M) error {
+	a.schema = schema
+	return nil
+}
+
+func property(t *testing.T, schema map[string]interface{}, path ...string) map[string]interface{} {
+	t.Helper()
+	for _, name := range path {
+		properties, _ := schema["properties"].(map[string]interface{})
+		schema, _ = properties[name].(map[string]interface{})
+		if schema == nil {
+			t.Fatalf("schema has no property %s", name)
+		}
+	}
+	return schema
+}
+
+func TestJSONSchemaOf(t *testing.T) {
+	schema := JSONSchemaOf(&memberRequest{})
+	if schema["$schema"] != JSONSchemaDialect || schema["title"] != "memberRequest" || schema["type"] != "object" {
+		t.Errorf("schema %v", schema)
+	}
+	if required := schema["required"]; !reflect.DeepEqual(required, []string{"email", "name"}) {
+		t.Errorf("required %v", required)
+	}
+	expected := map[string]map[string]interface{}{
+		"name":   {"type": "string"},
+		"email":  {"type": "string", "format": "email"},
+		"secret": {"type": "string", "minLength": 8},
+		"level":  {"type": "string", "enum": []string{"low", "high"}},
+		"age":    {"type": "integer", "minimum": 0},
+	}
+	for name, want := range expected {
+		if got := property(t, schema, name); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s is %v", name, got)
+		}
+	}
+	address := property(t, schema, "address")
+	if !reflect.DeepEqual(address["required"], []string{"city"}) || property(t, address, "city")["type"] != "string" {
+		t.Errorf("address is %v", address)
+	}
+
+	schema = JSONSchemaOf(account{})
+	if id := property(t, schema, "_id"); id["pattern"] != "^[0-9a-fA-F]{24}$" || id["readOnly"] != true {
+		t.Errorf("_id is %v", id)
+	}
+	if secret := property(t, schema, "secret"); secret["writeOnly"] != true {
+		t.Errorf("secret is %v", secret)
+	}
+	if tags := property(t, schema, "tags"); !reflect.DeepEqual(tags["items"], map[string]interface{}{"type": "string"}) {
+		t.Errorf("tags is %v", tags)
+	}
+
+	schema = JSONSchemaOf(node{})
+	if parent := property(t, schema, "parent"); !reflect.DeepEqual(parent, map[string]interface{}{"type": "object"}) {
+		t.Errorf("recursive parent is %v", parent)
+	}
+}
+
+func TestBSONSchemaOf(t *testing.T) {
+	schema := BSONSchemaOf(record{})
+	if _, ok := schema["$schema"]; ok || schema["bsonType"] != "object" || schema["title"] != "record" {
+		t.Errorf("schema %v", schema)
+	}
+	if required := schema["required"]; !reflect.DeepEqual(required, []string{"name"}) {
+		t.Errorf("required %v", required)
+	}
+	expected := map[string]map[string]interface{}{
+		"_id":  {"bsonType": []string{"objectId", "null"}},
+		"name": {"bsonType": "string"},
+		"rank": {"bsonType": []string{"int", "long", "null"}},
+	}
+	for name, want := range expected {
+		if got := property(t, schema, name); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s is %v", name, got)
+		}
+	}
+
+	schema = BSONSchemaOf(memberRequest{})
+	if level := property(t, schema, "level"); !reflect.DeepEqual(level["enum"], []interface{}{"low", "high", nil}) {
+		t.Errorf("level is %v", level)
+	}
+	if email := property(t, schema, "email"); !reflect.DeepEqual(email, map[string]interface{}{"bsonType": "string"}) {
+		t.Errorf("email is %v", email)
+	}
+
+	adapter := &validatingAdapter{MemoryAdapter: NewMemoryAdapter()}
+	records := newService("records", record{}).SetEntity(Entity{Ctx: context.Background(), Adapter: adapter})
+	if err := records.InstallValidator(); err != nil || adapter.schema != nil {
+		t.Errorf("validator installed without storage validation: %v %v", adapter.schema, err)
+	}
+	if err := records.SetStorageValidation().InstallValidator(); err != nil || !reflect.DeepEqual(adapter.schema, BSONSchemaOf(record{})) {
+		t.Errorf("installed validator %v %v", adapter.schema, err)
+	}
+}
+
+func TestServiceSchemas(t *testing.T) {
+	accounts := newService("accounts", account{}).
+		SetMulti(MethodCreate).
+		SetRequestSchema(MethodCreate, account{}).
+		SetRequestSchema(MethodPatch, Partial(record{})).
+		SetResponseSchema(MethodGet, account{})
+	schemas := accounts.Schemas()
+
+	names := func(schema map[string]interface{}) []string {
+		properties, _ := schema["properties"].(map[string]interface{})
+		result := []string{}
+		for name := range properties {
+			result = append(result, name)
+		}
+		sort.Strings(result)
+		return result
+	}
+	create := schemas.Requests[MethodCreate]
+	oneOf, _ := create["oneOf"].([]interface{})
+	if create["$schema"] != JSONSchemaDialect || len(oneOf) != 2 {
+		t.Fatalf("multi create request is %v", create)
+	}
+	item, _ := oneOf[0].(JSONSchema)
+	if got := names(item); !reflect.DeepEqual(got, []string{"email", "name", "role", "secret", "tags"}) {
+		t.Errorf("create request has %v", got)
+	}
+	if list, _ := oneOf[1].(JSONSchema); list["type"] != "array" || !reflect.DeepEqual(list["items"], item) {
+		t.Errorf("multi create list is %v", list)
+	}
+	patch := schemas.Requests[MethodPatch]
+	if _, ok := patch["required"]; ok || !reflect.DeepEqual(names(patch), []string{"name", "rank"}) {
+		t.Errorf("partial patch request is %v", patch)
+	}
+	if got := names(schemas.Responses[MethodGet]); !reflect.DeepEqual(got, []string{"_id", "email", "name", "role", "tags", "version"}) {
+		t.Errorf("get response has %v", got)
+	}
+
+	engine := fiber.New(fiber.Config{ErrorHandler: errorHandler})
+	engine.Get(APIPrefix+SchemasPath, SchemasController(map[string]*Service{"accounts": accounts}))
+	engine.Get(APIPrefix+SchemasPath+"/:service", SchemasController(map[string]*Service{"accounts": accounts}))
+	status, _, out := send(t, engine, "GET", SchemasPath, nil)
+	if _, ok := object(t, out)["accounts"]; status != fiber.StatusOK || !ok {
+		t.Errorf("schemas returned %d %v", status, out)
+	}
+	status, _, out = send(t, engine, "GET", SchemasPath+"/accounts", nil)
+	requests, _ := object(t, out)["requests"].(map[string]interface{})
+	if _, ok := requests[MethodPatch]; status != fiber.StatusOK || !ok {
+		t.Errorf("service schemas returned %d %v", status, out)
+	}
+	if status, _, out := send(t, engine, "GET", SchemasPath+"/missing", nil); status != fiber.StatusNotFound {
+		t.Errorf("schemas of a missing service returned %d %v", status, out)
+	}
+}
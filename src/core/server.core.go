@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -14,16 +15,25 @@ import (
 )
 
 type Server struct {
-	Port     int
-	Engine   *fiber.App
-	App      *App
-	Database *Database
+	Port    int
+	Engine  *fiber.App
+	App     *App
+	Storage Storage
 }
 
 func (s *Server) Boot() error {
 	return s.Engine.Listen(fmt.Sprintf(":%v", s.Port))
 }
 
+// Shutdown drains the in-flight requests and the event subscribers until ctx
+// is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.Engine.ShutdownWithContext(ctx); err != nil {
+		return err
+	}
+	return s.App.Events.Close(ctx)
+}
+
 var server *Server
 
 const (
@@ -34,12 +44,57 @@ type ServerError struct {
 	Status  int    `json:"status"`
 	Title   string `json:"title"`
 	Message string `json:"message"`
+
+	// Errors are the fields of an invalid payload.
+	Errors []FieldError `json:"errors,omitempty"`
 }
 
 func (e *ServerError) Error() string {
 	return e.Message
 }
 
+func NotFound(m string) *ServerError {
+	return &ServerError{Status: fiber.StatusNotFound, Title: "not-found", Message: m}
+}
+
+func BadRequest(m string) *ServerError {
+	return &ServerError{Status: fiber.StatusBadRequest, Title: "bad-request", Message: m}
+}
+
+func Unauthorized(m string) *ServerError {
+	return &ServerError{Status: fiber.StatusUnauthorized, Title: "unauthorized", Message: m}
+}
+
+func Forbidden(m string) *ServerError {
+	return &ServerError{Status: fiber.StatusForbidden, Title: "forbidden", Message: m}
+}
+
+func Conflict(m string) *ServerError {
+	return &ServerError{Status: fiber.StatusConflict, Title: "conflict", Message: m}
+}
+
+func PreconditionFailed(m string) *ServerError {
+	return &ServerError{Status: fiber.StatusPreconditionFailed, Title: "precondition-failed", Message: m}
+}
+
+func Unprocessable(m string) *ServerError {
+	return &ServerError{Status: fiber.StatusUnprocessableEntity, Title: "unprocessable-entity", Message: m}
+}
+
+func Unexpected(m string) *ServerError {
+	return &ServerError{Status: fiber.StatusInternalServerError, Title: "internal-server", Message: m}
+}
+
+func errorHandler(ctx *fiber.Ctx, err error) error {
+	if e, ok := err.(*ServerError); ok {
+		return ctx.Status(e.Status).JSON(e)
+	} else if e, ok := err.(*fiber.Error); ok {
+		return ctx.Status(e.Code).JSON(ServerError{Status: e.Code, Title: "internal-server", Message: e.Message})
+	} else {
+		return ctx.Status(500).JSON(ServerError{Status: 500, Title: "internal-server", Message: err.Error()})
+	}
+}
+
 func Build() *Server {
 	if server == nil {
 		stage := Configuration().STAGE
@@ -51,21 +106,13 @@ func Build() *Server {
 			allowOrigins = Configuration().AUDIENCE
 		}
 
-		database := InitDatabase()
+		storage := InitStorage()
 		port := Configuration().PORT
 		app := InitApp()
 		engine := fiber.New(fiber.Config{
-			ReadTimeout:  time.Duration(TimeoutInSeconds) * time.Second,
-			WriteTimeout: time.Duration(TimeoutInSeconds) * time.Second,
-			ErrorHandler: func(ctx *fiber.Ctx, err error) error {
-				if e, ok := err.(*ServerError); ok {
-					return ctx.Status(e.Status).JSON(e)
-				} else if e, ok := err.(*fiber.Error); ok {
-					return ctx.Status(e.Code).JSON(ServerError{Status: e.Code, Title: "internal-server", Message: e.Message})
-				} else {
-					return ctx.Status(500).JSON(ServerError{Status: 500, Title: "internal-server", Message: err.Error()})
-				}
-			},
+			ReadTimeout:           time.Duration(TimeoutInSeconds) * time.Second,
+			WriteTimeout:          time.Duration(TimeoutInSeconds) * time.Second,
+			ErrorHandler:          errorHandler,
 			DisableStartupMessage: true,
 		})
 		engine.Use(idempotency.New())
@@ -82,10 +129,10 @@ func Build() *Server {
 		})
 
 		server = &Server{
-			Port:     port,
-			Engine:   engine,
-			App:      app,
-			Database: database,
+			Port:    port,
+			Engine:  engine,
+			App:     app,
+			Storage: storage,
 		}
 		log.Infof("%s server listening on :%d\n", stage, port)
 	}
@@ -2,9 +2,16 @@ package core
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -15,8 +22,13 @@ type Path = string
 type FindHandlerOptions = *options.FindOptions
 type GetHandlerOptions = *options.FindOneOptions
 type CreateHandlerOptions = *options.InsertOneOptions
+type CreateManyHandlerOptions = *options.InsertManyOptions
 type PatchHandlerOptions = *options.FindOneAndUpdateOptions
+type UpdateHandlerOptions = *options.FindOneAndReplaceOptions
 type DeleteHandlerOptions = *options.FindOneAndDeleteOptions
+type CountHandlerOptions = *options.CountOptions
+type EstimatedCountHandlerOptions = *options.EstimatedDocumentCountOptions
+type AggregateHandlerOptions = *options.AggregateOptions
 
 type FindHandlerResponse struct {
 	Result    *mongo.Cursor
@@ -34,28 +46,119 @@ type PatchHandlerResponse struct {
 	Result    *mongo.SingleResult
 	Exception error
 }
+type UpdateHandlerResponse struct {
+	Result    *mongo.SingleResult
+	Exception error
+}
 type DeleteHandlerResponse struct {
 	Result    *mongo.SingleResult
 	Exception error
 }
+type AggregateHandlerResponse struct {
+	Result    *mongo.Cursor
+	Exception error
+}
+type CountHandlerResponse struct {
+	Result    int64
+	Exception error
+}
+
+// BulkItem is the outcome of a multi operation for one document.
+type BulkItem struct {
+	Index     int
+	Result    *mongo.SingleResult
+	Exception error
+}
+type BulkHandlerResponse struct {
+	Result    []BulkItem
+	Exception error
+}
 
 type FindHandler func(customFilter interface{}, customOptions FindHandlerOptions) FindHandlerResponse
 type GetHandler func(customFilter interface{}, customOptions GetHandlerOptions) GetHandlerResponse
 type CreateHandler func(customPayload interface{}, customOptions CreateHandlerOptions) CreateHandlerResponse
 type PatchHandler func(customFilter interface{}, customPayload interface{}, customOptions PatchHandlerOptions) PatchHandlerResponse
+type UpdateHandler func(customFilter interface{}, customPayload interface{}, customOptions UpdateHandlerOptions) UpdateHandlerResponse
 type DeleteHandler func(customFilter interface{}, customOptions DeleteHandlerOptions) DeleteHandlerResponse
+type CountHandler func(customFilter interface{}, customOptions CountHandlerOptions) CountHandlerResponse
+type EstimatedCountHandler func(customOptions EstimatedCountHandlerOptions) CountHandlerResponse
+type AggregateHandler func(customPipeline interface{}, customOptions AggregateHandlerOptions) AggregateHandlerResponse
+type CreateManyHandler func(customPayloads []interface{}, customOptions CreateManyHandlerOptions) BulkHandlerResponse
+type PatchManyHandler func(customFilter interface{}, customPayload interface{}, customOptions PatchHandlerOptions) BulkHandlerResponse
+type DeleteManyHandler func(customFilter interface{}, customOptions DeleteHandlerOptions) BulkHandlerResponse
+type RestoreHandler func(customFilter interface{}, customOptions PatchHandlerOptions) PatchHandlerResponse
+
+var ErrMultiNotAllowed = errors.New("multi operations are not allowed")
 
 type Handler struct {
-	Find   FindHandler
-	Get    GetHandler
-	Create CreateHandler
-	Patch  PatchHandler
-	Delete DeleteHandler
+	Find      FindHandler
+	Get       GetHandler
+	Create    CreateHandler
+	Patch     PatchHandler
+	Update    UpdateHandler
+	Delete    DeleteHandler
+	Count     CountHandler
+	Aggregate AggregateHandler
+
+	EstimatedCount EstimatedCountHandler
+
+	CreateMany CreateManyHandler
+	PatchMany  PatchManyHandler
+	DeleteMany DeleteManyHandler
+
+	Restore RestoreHandler
+	Purge   DeleteHandler
+
+	ctx     context.Context
+	scope   handlerScope
+	service *Service
+}
+
+type handlerScope struct {
+	user     string
+	archived bool
+	version  *int64
+}
+
+func (h Handler) Context() context.Context {
+	if h.ctx == nil {
+		return context.Background()
+	}
+	return h.ctx
+}
+
+// WithContext returns a copy of the handler running its storage calls and
+// event publishes with ctx.
+func (h Handler) WithContext(ctx context.Context) Handler {
+	if h.service == nil {
+		return h
+	}
+	return h.service.newHandler(ctx, h.scope)
+}
+
+// As returns a copy of the handler acting for user.
+func (h Handler) As(user string) Handler {
+	if h.service == nil {
+		return h
+	}
+	scope := h.scope
+	scope.user = user
+	return h.service.newHandler(h.ctx, scope)
+}
+
+// WithArchived returns a copy of the handler that also sees archived documents.
+func (h Handler) WithArchived() Handler {
+	if h.service == nil {
+		return h
+	}
+	scope := h.scope
+	scope.archived = true
+	return h.service.newHandler(h.ctx, scope)
 }
 
 type Entity struct {
-	Ctx        context.Context
-	Collection *mongo.Collection
+	Ctx     context.Context
+	Adapter Adapter
 }
 
 type Extras struct {
@@ -63,19 +166,48 @@ type Extras struct {
 	Authorize    bool
 }
 
-type Controller func(params map[string]interface{}) error
+const (
+	MethodFind   = "FIND"
+	MethodGet    = "GET"
+	MethodCreate = "CREATE"
+	MethodPatch  = "PATCH"
+	MethodUpdate = "PUT"
+	MethodDelete = "DELETE"
+)
 
+var Verbs = map[string]string{
+	MethodFind:   fiber.MethodGet,
+	MethodGet:    fiber.MethodGet,
+	MethodCreate: fiber.MethodPost,
+	MethodPatch:  fiber.MethodPatch,
+	MethodUpdate: fiber.MethodPut,
+	MethodDelete: fiber.MethodDelete,
+}
+
+// Route is a controller bound to a method and path.
 type Route struct {
+	Method     string
 	Path       string
 	Controller Controller
 	Extras     Extras
+	Default    bool
 }
-type Router map[string]Route
-type HookFunc func(c *fiber.Ctx) error
-type Hooks struct {
-	Before  HookFunc
-	After   HookFunc
-	OnError HookFunc
+type Router []Route
+
+func (r Router) Verify() error {
+	seen := make(map[string]string)
+	for _, route := range r {
+		verb, ok := Verbs[route.Method]
+		if !ok {
+			return fmt.Errorf("route %s %s: unknown method", route.Method, route.Path)
+		}
+		key := verb + " " + routePattern(route.Path)
+		if method, ok := seen[key]; ok {
+			return fmt.Errorf("route %s %s: conflicts with %s %s", route.Method, route.Path, method, route.Path)
+		}
+		seen[key] = route.Method
+	}
+	return nil
 }
 
 type Service struct {
@@ -85,6 +217,26 @@ type Service struct {
 	Handler Handler
 	Router  Router
 	Hooks   Hooks
+	Multi   map[string]bool
+	App     *App
+
+	SoftDelete bool
+	Timestamps bool
+	Versioning bool
+	Upsert     bool
+	Schema     interface{}
+	Access     Access
+	Requests   map[string]interface{}
+	Responses  map[string]interface{}
+
+	StorageValidation bool
+
+	Queryable    map[string]bool
+	Sortable     map[string]bool
+	Pagination   Pagination
+	PageMode     PageMode
+	CountMode    CountMode
+	Aggregations map[string]Aggregation
 }
 
 func Create() *Service {
@@ -97,11 +249,138 @@ func (s *Service) SetName(n Name) *Service {
 }
 func (s *Service) SetEntity(e Entity) *Service {
 	s.Entity = e
+	s.Handler = s.newHandler(e.Ctx, handlerScope{})
+	return s
+}
+
+// publish only loads the document when the event has subscribers.
+func (s *Service) publish(ctx context.Context, method string, eventType string, user string, document func() (Document, error)) {
+	if s.App == nil || !s.App.Listening(s.Name, eventType) {
+		return
+	}
+	data, err := document()
+	if err != nil {
+		log.Errorf("failed to load %s event document for %s : %s", eventType, s.Name, err.Error())
+		return
+	}
+	err = s.App.Publish(ctx, ServiceEvent{
+		Service:  s.Name,
+		Method:   method,
+		Type:     eventType,
+		Document: data,
+		User:     user,
+		Time:     time.Now(),
+	})
+	if err != nil {
+		log.Errorf("failed to publish %s event for %s : %s", eventType, s.Name, err.Error())
+	}
+}
+
+func (s *Service) matchingIDs(ctx context.Context, filter interface{}) ([]interface{}, error) {
+	cursor, err := s.Entity.Adapter.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	documents := []Document{}
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, err
+	}
+	ids := make([]interface{}, 0, len(documents))
+	for _, document := range documents {
+		ids = append(ids, document["_id"])
+	}
+	return ids, nil
+}
+
+func (s *Service) manages() bool {
+	return s.Timestamps || s.Versioning
+}
+
+func (s *Service) created(payload interface{}, now time.Time) (Document, error) {
+	document, err := ToDocument(payload)
+	if err != nil {
+		return nil, err
+	}
+	if s.Timestamps {
+		stampCreated(document, now)
+	}
+	if s.Versioning {
+		document[VersionField] = int64(1)
+	}
+	return document, nil
+}
+
+func (s *Service) patched(payload interface{}, now time.Time) (Operators, error) {
+	operators, ok := payload.(Operators)
+	if !ok {
+		document, err := ToDocument(payload)
+		if err != nil {
+			return nil, err
+		}
+		operators = Operators{"$set": document}
+	}
+	if s.Timestamps {
+		operators = stampPatch(operators, now)
+	}
+	if s.Versioning {
+		operators = versionPatch(operators)
+	}
+	return operators, nil
+}
+
+func (s *Service) replacement(ctx context.Context, filter interface{}, payload interface{}, now time.Time) (Document, error) {
+	document, err := s.created(payload, now)
+	if err != nil {
+		return nil, err
+	}
+	current := Document{}
+	projection := bson.M{CreatedAtField: 1, VersionField: 1}
+	err = s.Entity.Adapter.FindOne(ctx, filter, options.FindOne().SetProjection(projection)).Decode(&current)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	if createdAt, ok := current[CreatedAtField]; ok && s.Timestamps {
+		document[CreatedAtField] = createdAt
+	}
+	if version, ok := documentVersion(current); ok && s.Versioning {
+		document[VersionField] = version + 1
+	}
+	return document, nil
+}
 
-	h := Handler{}
+// writeError tells a version mismatch from a missing document.
+func (s *Service) writeError(ctx context.Context, filter interface{}, version *int64, err error) error {
+	if err != mongo.ErrNoDocuments || version == nil {
+		return err
+	}
+	count, countErr := s.Entity.Adapter.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if countErr == nil && count > 0 {
+		return ErrVersionMismatch
+	}
+	return err
+}
+
+func decodeResult(result *mongo.SingleResult) func() (Document, error) {
+	return func() (Document, error) {
+		document := Document{}
+		err := result.Decode(&document)
+		return document, err
+	}
+}
+
+func (s *Service) newHandler(ctx context.Context, hs handlerScope) Handler {
+	e := s.Entity
+	h := Handler{ctx: ctx, scope: hs, service: s}
+	user := hs.user
+	scope := func(filter interface{}) interface{} {
+		if s.hidesArchived(hs.archived) {
+			return active(filter)
+		}
+		return filter
+	}
 
 	h.Find = func(customFilter interface{}, customOptions FindHandlerOptions) FindHandlerResponse {
-		cursor, err := e.Collection.Find(e.Ctx, customFilter, customOptions)
+		cursor, err := e.Adapter.Find(ctx, scope(customFilter), customOptions)
 		if err != nil {
 			return FindHandlerResponse{
 				Result:    nil,
@@ -116,7 +395,7 @@ func (s *Service) SetEntity(e Entity) *Service {
 	}
 
 	h.Get = func(customFilter interface{}, customOptions GetHandlerOptions) GetHandlerResponse {
-		result := e.Collection.FindOne(e.Ctx, customFilter, customOptions)
+		result := e.Adapter.FindOne(ctx, scope(customFilter), customOptions)
 		if result.Err() != nil {
 			return GetHandlerResponse{
 				Result:    nil,
@@ -130,13 +409,28 @@ func (s *Service) SetEntity(e Entity) *Service {
 	}
 
 	h.Create = func(customPayload interface{}, customOptions CreateHandlerOptions) CreateHandlerResponse {
-		result, err := e.Collection.InsertOne(e.Ctx, customPayload, customOptions)
+		if s.manages() {
+			document, err := s.created(customPayload, time.Now().UTC())
+			if err != nil {
+				return CreateHandlerResponse{
+					Result:    nil,
+					Exception: err,
+				}
+			}
+			customPayload = document
+		}
+		result, err := e.Adapter.InsertOne(ctx, customPayload, customOptions)
 		if err != nil {
 			return CreateHandlerResponse{
 				Result:    nil,
 				Exception: err,
 			}
 		}
+		s.publish(ctx, MethodCreate, EventCreated, user, func() (Document, error) {
+			document := Document{}
+			err := e.Adapter.FindOne(ctx, bson.M{"_id": result.InsertedID}, options.FindOne()).Decode(&document)
+			return document, err
+		})
 		return CreateHandlerResponse{
 			Result:    result,
 			Exception: nil,
@@ -145,36 +439,265 @@ func (s *Service) SetEntity(e Entity) *Service {
 
 	h.Patch = func(customFilter interface{}, customPayload interface{}, customOptions PatchHandlerOptions) PatchHandlerResponse {
 		customOptions.SetReturnDocument(options.After)
-		customData := bson.M{"$set": customPayload}
-		result := e.Collection.FindOneAndUpdate(e.Ctx, customFilter, customData, customOptions)
+		var customData interface{} = bson.M{"$set": customPayload}
+		if operators, ok := customPayload.(Operators); ok {
+			if err := operators.Validate(); err != nil {
+				return PatchHandlerResponse{
+					Result:    nil,
+					Exception: err,
+				}
+			}
+			customData = operators
+		}
+		if s.manages() {
+			operators, err := s.patched(customPayload, time.Now().UTC())
+			if err != nil {
+				return PatchHandlerResponse{
+					Result:    nil,
+					Exception: err,
+				}
+			}
+			customData = operators
+		}
+		result := e.Adapter.FindOneAndUpdate(ctx, atVersion(scope(customFilter), hs.version), customData, customOptions)
 		if result.Err() != nil {
 			return PatchHandlerResponse{
 				Result:    nil,
-				Exception: result.Err(),
+				Exception: s.writeError(ctx, scope(customFilter), hs.version, result.Err()),
 			}
 		}
+		s.publish(ctx, MethodPatch, EventPatched, user, decodeResult(result))
 		return PatchHandlerResponse{
 			Result:    result,
 			Exception: nil,
 		}
 	}
 
+	h.Update = func(customFilter interface{}, customPayload interface{}, customOptions UpdateHandlerOptions) UpdateHandlerResponse {
+		customOptions.SetReturnDocument(options.After)
+		if s.manages() {
+			document, err := s.replacement(ctx, atVersion(scope(customFilter), hs.version), customPayload, time.Now().UTC())
+			if err != nil {
+				return UpdateHandlerResponse{
+					Result:    nil,
+					Exception: err,
+				}
+			}
+			customPayload = document
+		}
+		result := e.Adapter.FindOneAndReplace(ctx, atVersion(scope(customFilter), hs.version), customPayload, customOptions)
+		if result.Err() != nil {
+			return UpdateHandlerResponse{
+				Result:    nil,
+				Exception: s.writeError(ctx, scope(customFilter), hs.version, result.Err()),
+			}
+		}
+		s.publish(ctx, MethodUpdate, EventUpdated, user, decodeResult(result))
+		return UpdateHandlerResponse{
+			Result:    result,
+			Exception: nil,
+		}
+	}
+
 	h.Delete = func(customFilter interface{}, customOptions DeleteHandlerOptions) DeleteHandlerResponse {
-		result := e.Collection.FindOneAndDelete(e.Ctx, customFilter, customOptions)
+		var result *mongo.SingleResult
+		if s.SoftDelete {
+			archiveOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+			if customOptions != nil {
+				archiveOptions.Projection = customOptions.Projection
+				archiveOptions.Sort = customOptions.Sort
+			}
+			result = e.Adapter.FindOneAndUpdate(ctx, atVersion(scope(customFilter), hs.version), s.archiveUpdate(), archiveOptions)
+		} else {
+			result = e.Adapter.FindOneAndDelete(ctx, atVersion(customFilter, hs.version), customOptions)
+		}
 		if result.Err() != nil {
 			return DeleteHandlerResponse{
+				Result:    nil,
+				Exception: s.writeError(ctx, scope(customFilter), hs.version, result.Err()),
+			}
+		}
+		s.publish(ctx, MethodDelete, EventRemoved, user, decodeResult(result))
+		return DeleteHandlerResponse{
+			Result:    result,
+			Exception: nil,
+		}
+	}
+
+	h.CreateMany = func(customPayloads []interface{}, customOptions CreateManyHandlerOptions) BulkHandlerResponse {
+		if !s.Multi[MethodCreate] {
+			return BulkHandlerResponse{Exception: ErrMultiNotAllowed}
+		}
+		if customOptions == nil {
+			customOptions = options.InsertMany()
+		}
+		customOptions.SetOrdered(false)
+		documents := make([]interface{}, 0, len(customPayloads))
+		now := time.Now().UTC()
+		for _, payload := range customPayloads {
+			document, err := s.created(payload, now)
+			if err != nil {
+				return BulkHandlerResponse{Exception: err}
+			}
+			if _, ok := document["_id"]; !ok {
+				document["_id"] = primitive.NewObjectID()
+			}
+			documents = append(documents, document)
+		}
+		failures := make(map[int]error)
+		_, err := e.Adapter.InsertMany(ctx, documents, customOptions)
+		if err != nil {
+			bulk, ok := err.(mongo.BulkWriteException)
+			if !ok || len(bulk.WriteErrors) == 0 {
+				return BulkHandlerResponse{Exception: err}
+			}
+			for _, failure := range bulk.WriteErrors {
+				failures[failure.Index] = mongo.WriteException{WriteErrors: mongo.WriteErrors{failure.WriteError}}
+			}
+		}
+		items := make([]BulkItem, 0, len(documents))
+		for i, document := range documents {
+			if failure, ok := failures[i]; ok {
+				items = append(items, BulkItem{Index: i, Exception: failure})
+				continue
+			}
+			created := document.(Document)
+			s.publish(ctx, MethodCreate, EventCreated, user, func() (Document, error) {
+				return created, nil
+			})
+			items = append(items, BulkItem{Index: i, Result: mongo.NewSingleResultFromDocument(created, nil, nil)})
+		}
+		return BulkHandlerResponse{Result: items}
+	}
+
+	h.PatchMany = func(customFilter interface{}, customPayload interface{}, customOptions PatchHandlerOptions) BulkHandlerResponse {
+		if !s.Multi[MethodPatch] {
+			return BulkHandlerResponse{Exception: ErrMultiNotAllowed}
+		}
+		if customOptions == nil {
+			customOptions = options.FindOneAndUpdate()
+		}
+		ids, err := s.matchingIDs(ctx, scope(customFilter))
+		if err != nil {
+			return BulkHandlerResponse{Exception: err}
+		}
+		items := make([]BulkItem, 0, len(ids))
+		for i, id := range ids {
+			response := h.Patch(bson.M{"_id": id}, customPayload, customOptions)
+			items = append(items, BulkItem{Index: i, Result: response.Result, Exception: response.Exception})
+		}
+		return BulkHandlerResponse{Result: items}
+	}
+
+	h.DeleteMany = func(customFilter interface{}, customOptions DeleteHandlerOptions) BulkHandlerResponse {
+		if !s.Multi[MethodDelete] {
+			return BulkHandlerResponse{Exception: ErrMultiNotAllowed}
+		}
+		ids, err := s.matchingIDs(ctx, scope(customFilter))
+		if err != nil {
+			return BulkHandlerResponse{Exception: err}
+		}
+		items := make([]BulkItem, 0, len(ids))
+		for i, id := range ids {
+			response := h.Delete(bson.M{"_id": id}, customOptions)
+			items = append(items, BulkItem{Index: i, Result: response.Result, Exception: response.Exception})
+		}
+		return BulkHandlerResponse{Result: items}
+	}
+
+	h.Aggregate = func(customPipeline interface{}, customOptions AggregateHandlerOptions) AggregateHandlerResponse {
+		if s.hidesArchived(hs.archived) {
+			pipeline, err := activePipeline(customPipeline)
+			if err != nil {
+				return AggregateHandlerResponse{
+					Result:    nil,
+					Exception: err,
+				}
+			}
+			customPipeline = pipeline
+		}
+		cursor, err := e.Adapter.Aggregate(ctx, customPipeline, customOptions)
+		if err != nil {
+			return AggregateHandlerResponse{
+				Result:    nil,
+				Exception: err,
+			}
+		}
+		return AggregateHandlerResponse{
+			Result:    cursor,
+			Exception: nil,
+		}
+	}
+
+	h.Count = func(customFilter interface{}, customOptions CountHandlerOptions) CountHandlerResponse {
+		result, err := e.Adapter.CountDocuments(ctx, scope(customFilter), customOptions)
+		if err != nil {
+			return CountHandlerResponse{
+				Result:    0,
+				Exception: err,
+			}
+		}
+		return CountHandlerResponse{
+			Result:    result,
+			Exception: nil,
+		}
+	}
+
+	h.Restore = func(customFilter interface{}, customOptions PatchHandlerOptions) PatchHandlerResponse {
+		if !s.SoftDelete {
+			return PatchHandlerResponse{
+				Result:    nil,
+				Exception: ErrSoftDeleteDisabled,
+			}
+		}
+		if customOptions == nil {
+			customOptions = options.FindOneAndUpdate()
+		}
+		customOptions.SetReturnDocument(options.After)
+		result := e.Adapter.FindOneAndUpdate(ctx, archivedOnly(customFilter), s.restoreUpdate(), customOptions)
+		if result.Err() != nil {
+			return PatchHandlerResponse{
 				Result:    nil,
 				Exception: result.Err(),
 			}
 		}
+		s.publish(ctx, MethodPatch, EventRestored, user, decodeResult(result))
+		return PatchHandlerResponse{
+			Result:    result,
+			Exception: nil,
+		}
+	}
+
+	h.Purge = func(customFilter interface{}, customOptions DeleteHandlerOptions) DeleteHandlerResponse {
+		result := e.Adapter.FindOneAndDelete(ctx, customFilter, customOptions)
+		if result.Err() != nil {
+			return DeleteHandlerResponse{
+				Result:    nil,
+				Exception: result.Err(),
+			}
+		}
+		s.publish(ctx, MethodDelete, EventPurged, user, decodeResult(result))
 		return DeleteHandlerResponse{
 			Result:    result,
 			Exception: nil,
 		}
 	}
 
-	s.Handler = h
-	return s
+	h.EstimatedCount = func(customOptions EstimatedCountHandlerOptions) CountHandlerResponse {
+		result, err := e.Adapter.EstimatedDocumentCount(ctx, customOptions)
+		if err != nil {
+			return CountHandlerResponse{
+				Result:    0,
+				Exception: err,
+			}
+		}
+		return CountHandlerResponse{
+			Result:    result,
+			Exception: nil,
+		}
+	}
+
+	return h
 }
 
 func (s *Service) SetPath(p Path) *Service {
@@ -182,95 +705,332 @@ func (s *Service) SetPath(p Path) *Service {
 	return s
 }
 
-func (s *Service) AddPublicRoute(method string, controller func(params map[string]interface{}) error, path ...string) *Service {
-	if s.Router == nil {
-		s.Router = make(map[string]Route)
+func routePattern(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = ":"
+		}
 	}
+	return strings.Join(segments, "/")
+}
+
+func (s *Service) addRoute(method string, controller Controller, extras Extras, path ...string) *Service {
 	route := Route{}
+	route.Method = method
 	route.Path = s.Path
 	if len(path) > 0 {
 		route.Path += path[0]
 	}
-	route.Extras = Extras{
-		Authenticate: false,
-		Authorize:    false,
-	}
 	route.Controller = controller
-	s.Router[method] = route
+	route.Extras = extras
+	return s.add(route)
+}
+
+func (s *Service) add(route Route) *Service {
+	for i, existing := range s.Router {
+		if Verbs[existing.Method] != Verbs[route.Method] || routePattern(existing.Path) != routePattern(route.Path) {
+			continue
+		}
+		if existing.Default {
+			s.Router[i] = route
+			return s
+		}
+		if route.Default {
+			return s
+		}
+	}
+	s.Router = append(s.Router, route)
 	return s
 }
-func (s *Service) AddPrivateRoute(method string, controller func(params map[string]interface{}) error, path ...string) *Service {
-	if s.Router == nil {
-		s.Router = make(map[string]Route)
+
+// SetResource adds the controllers of r as default routes, overridden by the
+// routes added for the same method and path.
+func (s *Service) SetResource(r Resource) *Service {
+	if verifier, ok := r.(interface{ Verify() error }); ok {
+		if err := verifier.Verify(); err != nil {
+			log.Fatalf("failed to set the resource of service %s %s", s.Name, err)
+		}
 	}
-	route := Route{}
-	route.Path = s.Path
-	if len(path) > 0 {
-		route.Path += path[0]
+	private := Extras{Authenticate: true, Authorize: false}
+	protected := Extras{Authenticate: true, Authorize: true}
+	routes := []Route{
+		{Method: MethodFind, Controller: r.Find, Extras: private},
+		{Method: MethodGet, Path: "/:id", Controller: r.Get, Extras: private},
+		{Method: MethodCreate, Controller: r.Create, Extras: private},
+		{Method: MethodPatch, Path: "/:id", Controller: r.Patch, Extras: private},
+		{Method: MethodPatch, Controller: r.PatchMany, Extras: protected},
+		{Method: MethodUpdate, Path: "/:id", Controller: r.Update, Extras: private},
+		{Method: MethodDelete, Path: "/:id", Controller: r.Delete, Extras: private},
+		{Method: MethodDelete, Controller: r.DeleteMany, Extras: protected},
+		{Method: MethodPatch, Path: "/:id/restore", Controller: r.Restore, Extras: protected},
+		{Method: MethodDelete, Path: "/:id/purge", Controller: r.Purge, Extras: protected},
 	}
-	route.Controller = controller
-	route.Extras = Extras{
+	for _, route := range routes {
+		route.Path = s.Path + route.Path
+		route.Default = true
+		s.add(route)
+	}
+	return s
+}
+
+func (s *Service) AddPublicRoute(method string, controller Controller, path ...string) *Service {
+	return s.addRoute(method, controller, Extras{
+		Authenticate: false,
+		Authorize:    false,
+	}, path...)
+}
+
+func (s *Service) AddPrivateRoute(method string, controller Controller, path ...string) *Service {
+	return s.addRoute(method, controller, Extras{
 		Authenticate: true,
 		Authorize:    false,
+	}, path...)
+}
+
+func (s *Service) AddProtectedRoute(method string, controller Controller, path ...string) *Service {
+	return s.addRoute(method, controller, Extras{
+		Authenticate: true,
+		Authorize:    true,
+	}, path...)
+}
+
+// SetMulti allows the multi operations of the given methods. REST multi creates
+// only run on protected routes.
+func (s *Service) SetMulti(methods ...string) *Service {
+	s.Multi = make(map[string]bool, len(methods))
+	for _, method := range methods {
+		s.Multi[method] = true
 	}
-	s.Router[method] = route
 	return s
 }
 
-func (s *Service) AddProtectedRoute(method string, controller func(params map[string]interface{}) error, path ...string) *Service {
-	if s.Router == nil {
-		s.Router = make(map[string]Route)
+// SetUpsert makes Update create the document when no document has its id.
+func (s *Service) SetUpsert() *Service {
+	s.Upsert = true
+	return s
+}
+
+// AddAggregation declares a named pipeline, served by AggregateController.
+func (s *Service) AddAggregation(name string, aggregation Aggregation) *Service {
+	if s.Aggregations == nil {
+		s.Aggregations = make(map[string]Aggregation)
 	}
-	route := Route{}
-	route.Path = s.Path
-	if len(path) > 0 {
-		route.Path += path[0]
+	s.Aggregations[name] = aggregation
+	return s
+}
+
+// SetSchema sets the struct typing parsed queries and holding the access tags.
+func (s *Service) SetSchema(schema interface{}) *Service {
+	s.Schema = schema
+	s.Access = AccessOf(schema)
+	return s
+}
+
+// SetQueryable sets the fields queries may filter on.
+func (s *Service) SetQueryable(fields ...string) *Service {
+	s.Queryable = make(map[string]bool, len(fields))
+	for _, field := range fields {
+		s.Queryable[field] = true
 	}
-	route.Controller = controller
-	route.Extras = Extras{
-		Authenticate: true,
-		Authorize:    true,
+	return s
+}
+
+// SetSortable sets the fields queries may sort on.
+func (s *Service) SetSortable(fields ...string) *Service {
+	s.Sortable = make(map[string]bool, len(fields))
+	for _, field := range fields {
+		s.Sortable[field] = true
+	}
+	return s
+}
+
+func (s *Service) SetPagination(p Pagination) *Service {
+	s.Pagination = p
+	return s
+}
+
+// FindOptions builds the find options of a query, bounded by the pagination.
+func (s *Service) FindOptions(ctx context.Context, query Query) FindHandlerOptions {
+	findOptions := query.FindOptions()
+	findOptions.Limit = nil
+	if limit := s.Pagination.Limit(ctx, query.Limit); limit > 0 {
+		findOptions.SetLimit(limit)
 	}
-	s.Router[method] = route
+	return findOptions
+}
+
+// SetPageMode sets how Find pages its results, PageSkip by default. PageCursor
+// rejects sorts on fields of the schema that may be null or missing.
+func (s *Service) SetPageMode(mode PageMode) *Service {
+	s.PageMode = mode
 	return s
 }
 
+// SetCountMode sets how pages are counted, CountExact by default.
+func (s *Service) SetCountMode(mode CountMode) *Service {
+	s.CountMode = mode
+	return s
+}
+
+// PageOptions returns the page options of the service for a parsed query.
+func (s *Service) PageOptions(query Query) PageOptions {
+	return PageOptions{
+		Mode:   s.PageMode,
+		Count:  s.CountMode,
+		Cursor: query.Cursor,
+	}
+}
+
+// Query parses a raw query string against the service schema and whitelists.
+func (s *Service) Query(raw string) (Query, error) {
+	query, err := ParseQuery(raw, s.Schema)
+	if err != nil {
+		return Query{}, err
+	}
+	if err := query.Allow(s.Queryable, s.Sortable); err != nil {
+		return Query{}, err
+	}
+	if s.PageMode == PageCursor && s.Schema != nil {
+		if err := cursorSortable(query.Sort, s.Schema); err != nil {
+			return Query{}, err
+		}
+	}
+	return query, nil
+}
+
 func (s *Service) SetHooks(h Hooks) *Service {
 	s.Hooks = h
 	return s
 }
 
-func (s *Service) Bind(controller func(params map[string]interface{}) error, server *Server) func(c *fiber.Ctx) error {
+func (s *Service) Bind(route Route, server *Server) func(c *fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
-		params := make(map[string]interface{})
-		params["ctx"] = c
-		params["handler"] = s.Handler
-		params["entity"] = s.Entity
-		params["database"] = server.Database
-		params["app"] = server.App
-
-		if s.Hooks.Before != nil {
-			if err := s.Hooks.Before(c); err != nil {
-				return err
-			}
+		user, _ := c.Locals("user").(string)
+		role, _ := c.Locals("role").(string)
+		handler, err := s.ifMatch(c, route.Method, s.Handler.WithContext(c.UserContext()).As(user))
+		if err != nil {
+			return err
+		}
+		cc := &ControllerContext{
+			Ctx:       c,
+			Context:   c.UserContext(),
+			Method:    route.Method,
+			Handler:   handler,
+			Service:   s,
+			Entity:    s.Entity,
+			App:       server.App,
+			Principal: Principal{User: user, Role: role},
+			Data:      parseData(c),
+			Params: Params{
+				Query:    queryValues(c),
+				Route:    c.AllParams(),
+				User:     user,
+				Provider: ProviderRest,
+			},
+			ContentType: string(c.Request().Header.ContentType()),
+			rawQuery:    string(c.Request().URI().QueryString()),
 		}
 
-		err := controller(params)
+		result, err := s.dispatch(route, cc)
+		if err != nil || result == nil {
+			return err
+		}
+		if s.tag(c, route.Method, result) {
+			c.Response().ResetBody()
+			c.Status(fiber.StatusNotModified)
+			return nil
+		}
+		response, err := s.output(cc, result)
 		if err != nil {
-			if s.Hooks.OnError != nil {
-				if hookErr := s.Hooks.OnError(c); hookErr != nil {
-					return hookErr
-				}
-			}
 			return err
 		}
+		if cc.Status != 0 {
+			c.Status(cc.Status)
+		}
+		return c.JSON(response)
+	}
+}
 
-		if s.Hooks.After != nil {
-			if err := s.Hooks.After(c); err != nil {
-				return err
+// dispatch runs a REST or internal call. A nil result with no error means a
+// before hook halted it.
+func (s *Service) dispatch(route Route, cc *ControllerContext) (interface{}, error) {
+	hc := &HookContext{
+		App:     cc.App,
+		Service: s,
+		Method:  route.Method,
+		Type:    HookBefore,
+		Ctx:     cc.Ctx,
+		Data:    cc.Data,
+		Params:  cc.Params,
+	}
+	query := copyParams(cc.Params.Query)
+
+	var global Hooks
+	if cc.App != nil {
+		global = cc.App.Hooks
+	}
+	before := append(global.Before.For(route.Method), s.Hooks.Before.For(route.Method)...)
+	after := append(s.Hooks.After.For(route.Method), global.After.For(route.Method)...)
+	onError := append(s.Hooks.OnError.For(route.Method), global.OnError.For(route.Method)...)
+
+	var err error
+	multi := s.Multi[route.Method]
+	items, many := hc.Data.([]interface{})
+	if many && multi && route.Method == MethodCreate && !route.Extras.Authorize && !cc.Principal.Internal {
+		return nil, Forbidden("multi create needs a protected route")
+	}
+	if many && multi {
+		err = runHooksEach(before, hc, items)
+	} else {
+		err = runHooks(before, hc)
+	}
+	halted := err == Halt
+	if halted {
+		if hc.Result == nil {
+			return nil, nil
+		}
+		err = nil
+	}
+	if err == nil && hc.Result == nil {
+		if !cc.Principal.Internal {
+			err = fields(hc.Data)
+		}
+		if err == nil {
+			err = s.input(route.Method, cc.Principal, hc)
+		}
+		if err == nil {
+			err = s.validate(route.Method, hc)
+		}
+		if err == nil {
+			cc.Data, cc.Params = hc.Data, hc.Params
+			if !reflect.DeepEqual(hc.Params.Query, query) {
+				cc.rawQuery = hc.Params.Query.Encode()
 			}
+			err = route.Controller(cc)
+			hc.Result = cc.Result
+		}
+	}
+	if err == nil && !halted {
+		hc.Type = HookAfter
+		if results, ok := hc.Result.(BulkResults); ok && multi {
+			runResultHooksEach(after, hc, results)
+		} else if err = runHooks(after, hc); err == Halt {
+			err = nil
+		}
+	}
+	if err != nil {
+		hc.Type = HookError
+		hc.Error = err
+		if hookErr := runHooks(onError, hc); hookErr != nil && hookErr != Halt {
+			return nil, hookErr
+		}
+		if hc.Error != nil {
+			return nil, hc.Error
+		}
+		if hc.Result == nil {
+			return nil, Unexpected("error hooks cleared the error without a result")
 		}
-
-		return nil
 	}
+	return hc.Result, nil
 }
@@ -0,0 +1,212 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type record struct {
+	ID   primitive.ObjectID `json:"_id" bson:"_id" access:"readonly"`
+	Name string             `json:"name" bson:"name" binding:"required"`
+	Rank int64              `json:"rank" bson:"rank"`
+}
+
+type account struct {
+	ID      primitive.ObjectID `json:"_id" bson:"_id" access:"readonly"`
+	Name    string             `json:"name" bson:"name"`
+	Email   string             `json:"email" bson:"email" access:"writeonce"`
+	Secret  string             `json:"secret,omitempty" bson:"secret" access:"hidden"`
+	Role    string             `json:"role" bson:"role" access:"admin"`
+	Tags    []string           `json:"tags" bson:"tags"`
+	Version int64              `json:"version" bson:"version" access:"readonly"`
+}
+
+func newService(name string, schema interface{}) *Service {
+	return Create().
+		SetName(name).
+		SetPath("/" + name).
+		SetEntity(Entity{Ctx: context.Background(), Adapter: NewMemoryAdapter()}).
+		SetSchema(schema)
+}
+
+// serve binds services on an engine, reading the principal from the X-User
+// and X-Role headers.
+func serve(app *App, services ...*Service) *fiber.App {
+	registry := Services{}
+	for _, service := range services {
+		registry[service.Name] = service
+	}
+	app.InitServices(registry)
+	server := &Server{App: app}
+	engine := fiber.New(fiber.Config{ErrorHandler: errorHandler})
+	router := engine.Group(APIPrefix)
+	for _, service := range services {
+		for _, route := range service.Router {
+			router.Add(Verbs[route.Method], route.Path, func(c *fiber.Ctx) error {
+				c.Locals("user", c.Get("X-User"))
+				c.Locals("role", c.Get("X-Role"))
+				return c.Next()
+			}, service.Bind(route, server))
+		}
+	}
+	return engine
+}
+
+func send(t *testing.T, engine *fiber.App, method, path string, body interface{}, headers ...string) (int, http.Header, interface{}) {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		payload, ok := body.([]byte)
+		if !ok {
+			var err error
+			if payload, err = json.Marshal(body); err != nil {
+				t.Fatal(err)
+			}
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req := httptest.NewRequest(method, APIPrefix+path, reader)
+	req.Header.Set("Content-Type", fiber.MIMEApplicationJSON)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := engine.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out interface{}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &out); err != nil {
+			t.Fatalf("%s %s returned %s", method, path, raw)
+		}
+	}
+	return resp.StatusCode, resp.Header, out
+}
+
+func object(t *testing.T, value interface{}) map[string]interface{} {
+	t.Helper()
+	document, ok := value.(map[string]interface{})
+	if !ok {
+		t.Fatalf("%v is not an object", value)
+	}
+	return document
+}
+
+func TestStalledSubscriberDoesNotBlockWrites(t *testing.T) {
+	app := InitApp().SetPublishTimeout(50 * time.Millisecond)
+	app.Events = NewEventBus(1)
+	records := newService("records", record{}).SetResource(CRUD[record, record]{})
+	engine := serve(app, records)
+	subscription, err := app.Subscribe("records", EventCreated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscription.Unsubscribe()
+
+	for i := 0; i < 3; i++ {
+		start := time.Now()
+		status, _, out := send(t, engine, "POST", "/records", map[string]interface{}{"name": "record"}, "X-User", "user")
+		if status != fiber.StatusCreated {
+			t.Fatalf("create returned %d %v", status, out)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("create waited %s for the stalled subscriber", elapsed)
+		}
+	}
+	if message := <-subscription.C; message.Topic != EventTopic("records", EventCreated) {
+		t.Fatalf("subscriber received %v", message)
+	}
+	count, err := records.Entity.Adapter.CountDocuments(context.Background(), Document{}, nil)
+	if err != nil || count != 3 {
+		t.Fatalf("stored %d records, %v", count, err)
+	}
+}
+
+func TestMultiCreate(t *testing.T) {
+	records := newService("records", record{}).
+		SetMulti(MethodCreate).
+		SetResource(CRUD[record, record]{}).
+		AddProtectedRoute(MethodCreate, CRUD[record, record]{}.Create)
+	if err := records.Entity.Adapter.CreateIndex(context.Background(), "name", true); err != nil {
+		t.Fatal(err)
+	}
+	engine := serve(InitApp(), records)
+
+	items := []map[string]interface{}{{"name": "a"}, {"name": "a"}, {"name": "b"}}
+	status, _, out := send(t, engine, "POST", "/records", items, "X-User", "admin", "X-Role", AdminRole)
+	if status != fiber.StatusMultiStatus {
+		t.Fatalf("multi create returned %d %v", status, out)
+	}
+	results, _ := out.([]interface{})
+	if len(results) != 3 {
+		t.Fatalf("multi create returned %v", out)
+	}
+	for i, name := range []string{"a", "", "b"} {
+		result := object(t, results[i])
+		if result["index"] != float64(i) {
+			t.Errorf("item %d has the index %v", i, result["index"])
+		}
+		if name == "" {
+			if failure := object(t, result["error"]); failure["status"] != float64(fiber.StatusConflict) || result["data"] != nil {
+				t.Errorf("duplicate item returned %v", result)
+			}
+			continue
+		}
+		if data := object(t, result["data"]); data["name"] != name || data["_id"] == nil || result["error"] != nil {
+			t.Errorf("item %d returned %v", i, result)
+		}
+	}
+}
+
+func TestMultiCreatePublicRoute(t *testing.T) {
+	records := newService("records", record{}).
+		SetMulti(MethodCreate).
+		SetResource(CRUD[record, record]{}).
+		AddPublicRoute(MethodCreate, CRUD[record, record]{}.Create)
+	engine := serve(InitApp(), records)
+
+	items := []map[string]interface{}{{"name": "a"}, {"name": "b"}}
+	if status, _, out := send(t, engine, "POST", "/records", items); status != fiber.StatusForbidden {
+		t.Fatalf("public multi create returned %d %v", status, out)
+	}
+	if status, _, out := send(t, engine, "POST", "/records", items[0]); status != fiber.StatusCreated {
+		t.Fatalf("public create returned %d %v", status, out)
+	}
+	bulk, err := Calls[record](records).CreateMany(context.Background(), []interface{}{Document{"name": "c"}}, Params{})
+	if err != nil || len(bulk) != 1 || bulk[0].Data == nil || bulk[0].Data.Name != "c" {
+		t.Fatalf("internal multi create returned %v %v", bulk, err)
+	}
+	count, err := records.Entity.Adapter.CountDocuments(context.Background(), Document{}, nil)
+	if err != nil || count != 2 {
+		t.Fatalf("stored %d records, %v", count, err)
+	}
+}
+
+func TestInternalCallVersion(t *testing.T) {
+	accounts, _, _, id := newAccounts(t)
+	stale := int64(0)
+	var serverError *ServerError
+	if _, err := Calls[account](accounts).Patch(context.Background(), id, Document{"name": "Grace"}, Params{Version: &stale}); !errors.As(err, &serverError) || serverError.Status != fiber.StatusPreconditionFailed {
+		t.Fatalf("internal patch at a stale version returned %v", err)
+	}
+	current := int64(1)
+	item, err := Calls[account](accounts).Patch(context.Background(), id, Document{"name": "Grace"}, Params{Version: &current})
+	if err != nil || item.Name != "Grace" || item.Version != 2 {
+		t.Fatalf("internal patch at the current version returned %+v %v", item, err)
+	}
+}
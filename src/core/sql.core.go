@@ -0,0 +1,925 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2/log"
+	_ "github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	SQLite   = "sqlite"
+	Postgres = "postgres"
+)
+
+const sqliteTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+type columnKind int
+
+const (
+	idColumn columnKind = iota
+	stringColumn
+	boolColumn
+	intColumn
+	floatColumn
+	timeColumn
+	jsonColumn
+)
+
+type sqlColumn struct {
+	Name     string
+	Kind     columnKind
+	Nullable bool
+}
+
+var (
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+func columnKindOf(t reflect.Type) columnKind {
+	switch {
+	case t == objectIDType:
+		return idColumn
+	case t == timeType:
+		return timeColumn
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return stringColumn
+	case reflect.Bool:
+		return boolColumn
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return intColumn
+	case reflect.Float32, reflect.Float64:
+		return floatColumn
+	case reflect.Ptr:
+		return columnKindOf(t.Elem())
+	}
+	return jsonColumn
+}
+
+func schemaColumns(schema interface{}) []sqlColumn {
+	columns := []sqlColumn{{Name: "_id", Kind: idColumn}}
+	t := reflect.TypeOf(schema)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return columns
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("bson"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		if name == "_id" {
+			continue
+		}
+		nullable := false
+		switch field.Type.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+			nullable = true
+		}
+		for _, option := range tag[1:] {
+			nullable = nullable || option == "omitempty"
+		}
+		columns = append(columns, sqlColumn{Name: name, Kind: columnKindOf(field.Type), Nullable: nullable})
+	}
+	return columns
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+type SQLAdapter struct {
+	DB      *sql.DB
+	Dialect string
+	Table   string
+	columns []sqlColumn
+	kinds   map[string]columnKind
+}
+
+func NewSQLAdapter(ctx context.Context, db *sql.DB, dialect string, table string, schema interface{}) (*SQLAdapter, error) {
+	columns := schemaColumns(schema)
+	kinds := make(map[string]columnKind, len(columns))
+	for _, column := range columns {
+		kinds[column.Name] = column.Kind
+	}
+	adapter := &SQLAdapter{
+		DB:      db,
+		Dialect: dialect,
+		Table:   table,
+		columns: columns,
+		kinds:   kinds,
+	}
+	definitions := make([]string, 0, len(columns))
+	for _, column := range columns {
+		definition := quoteIdentifier(column.Name) + " " + adapter.columnType(column.Kind)
+		if column.Name == "_id" {
+			definition += " PRIMARY KEY"
+		}
+		definitions = append(definitions, definition)
+	}
+	statement := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", quoteIdentifier(table), strings.Join(definitions, ", "))
+	if _, err := db.ExecContext(ctx, statement); err != nil {
+		return nil, err
+	}
+	return adapter, nil
+}
+
+func (s *SQLAdapter) columnType(kind columnKind) string {
+	switch kind {
+	case boolColumn:
+		return "BOOLEAN"
+	case intColumn:
+		return "BIGINT"
+	case floatColumn:
+		return "DOUBLE PRECISION"
+	case timeColumn:
+		if s.Dialect == Postgres {
+			return "TIMESTAMPTZ"
+		}
+		return "TEXT"
+	}
+	return "TEXT"
+}
+
+type sqlStatement struct {
+	dialect string
+	args    []interface{}
+}
+
+func (q *sqlStatement) bind(value interface{}) string {
+	q.args = append(q.args, value)
+	if q.dialect == Postgres {
+		return fmt.Sprintf("$%d", len(q.args))
+	}
+	return "?"
+}
+
+func (s *SQLAdapter) toColumnValue(kind columnKind, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch kind {
+	case idColumn:
+		if id, ok := value.(primitive.ObjectID); ok {
+			return id.Hex(), nil
+		}
+		return fmt.Sprint(value), nil
+	case timeColumn:
+		var t time.Time
+		switch v := value.(type) {
+		case primitive.DateTime:
+			t = v.Time()
+		case time.Time:
+			t = v
+		default:
+			return nil, fmt.Errorf("expected a date, got %T", value)
+		}
+		if s.Dialect == Postgres {
+			return t.UTC(), nil
+		}
+		return t.UTC().Format(sqliteTimeLayout), nil
+	case jsonColumn:
+		data, err := bson.MarshalExtJSON(bson.M{"v": value}, true, false)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	case intColumn:
+		if number, ok := toFloat(value); ok {
+			return int64(number), nil
+		}
+	case floatColumn:
+		if number, ok := toFloat(value); ok {
+			return number, nil
+		}
+	}
+	return value, nil
+}
+
+func (s *SQLAdapter) fromColumnValue(kind columnKind, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if raw, ok := value.([]byte); ok {
+		value = string(raw)
+	}
+	switch kind {
+	case idColumn:
+		text := fmt.Sprint(value)
+		if id, err := primitive.ObjectIDFromHex(text); err == nil {
+			return id, nil
+		}
+		return text, nil
+	case boolColumn:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case int64:
+			return v != 0, nil
+		}
+	case timeColumn:
+		switch v := value.(type) {
+		case time.Time:
+			return v.UTC(), nil
+		case string:
+			return time.Parse(time.RFC3339Nano, v)
+		}
+	case jsonColumn:
+		var document bson.M
+		if err := bson.UnmarshalExtJSON([]byte(fmt.Sprint(value)), true, &document); err != nil {
+			return nil, err
+		}
+		return document["v"], nil
+	}
+	return value, nil
+}
+
+func (s *SQLAdapter) column(name string) (columnKind, error) {
+	kind, ok := s.kinds[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown field %s", name)
+	}
+	return kind, nil
+}
+
+func (s *SQLAdapter) where(q *sqlStatement, filter Document) (string, error) {
+	clauses := []string{}
+	for key, condition := range filter {
+		switch key {
+		case "$and", "$or", "$nor":
+			items, ok := condition.(primitive.A)
+			if !ok {
+				return "", fmt.Errorf("%s needs an array", key)
+			}
+			nested := []string{}
+			for _, item := range items {
+				document, ok := item.(Document)
+				if !ok {
+					return "", fmt.Errorf("%s needs an array of documents", key)
+				}
+				clause, err := s.where(q, document)
+				if err != nil {
+					return "", err
+				}
+				nested = append(nested, clause)
+			}
+			if len(nested) == 0 {
+				continue
+			}
+			switch key {
+			case "$and":
+				clauses = append(clauses, "("+strings.Join(nested, " AND ")+")")
+			case "$or":
+				clauses = append(clauses, "("+strings.Join(nested, " OR ")+")")
+			case "$nor":
+				clauses = append(clauses, "NOT ("+strings.Join(nested, " OR ")+")")
+			}
+			continue
+		}
+		if strings.HasPrefix(key, "$") {
+			return "", fmt.Errorf("unsupported operator %s", key)
+		}
+		kind, err := s.column(key)
+		if err != nil {
+			return "", err
+		}
+		operators, ok := isOperatorDocument(condition)
+		if !ok {
+			operators = Document{"$eq": condition}
+		}
+		for operator, target := range operators {
+			clause, err := s.compare(q, key, kind, operator, target)
+			if err != nil {
+				return "", err
+			}
+			clauses = append(clauses, clause)
+		}
+	}
+	if len(clauses) == 0 {
+		return "1 = 1", nil
+	}
+	return strings.Join(clauses, " AND "), nil
+}
+
+func (s *SQLAdapter) compare(q *sqlStatement, name string, kind columnKind, operator string, target interface{}) (string, error) {
+	column := quoteIdentifier(name)
+	switch operator {
+	case "$eq", "$ne":
+		if target == nil {
+			if operator == "$eq" {
+				return column + " IS NULL", nil
+			}
+			return column + " IS NOT NULL", nil
+		}
+		value, err := s.toColumnValue(kind, target)
+		if err != nil {
+			return "", err
+		}
+		if operator == "$eq" {
+			return column + " = " + q.bind(value), nil
+		}
+		return "(" + column + " <> " + q.bind(value) + " OR " + column + " IS NULL)", nil
+	case "$gt", "$gte", "$lt", "$lte":
+		value, err := s.toColumnValue(kind, target)
+		if err != nil {
+			return "", err
+		}
+		symbols := map[string]string{"$gt": " > ", "$gte": " >= ", "$lt": " < ", "$lte": " <= "}
+		return column + symbols[operator] + q.bind(value), nil
+	case "$in", "$nin":
+		items, ok := target.(primitive.A)
+		if !ok {
+			return "", fmt.Errorf("%s needs an array", operator)
+		}
+		if len(items) == 0 {
+			if operator == "$in" {
+				return "1 = 0", nil
+			}
+			return "1 = 1", nil
+		}
+		placeholders := []string{}
+		nullable := false
+		for _, item := range items {
+			if item == nil {
+				nullable = true
+				continue
+			}
+			value, err := s.toColumnValue(kind, item)
+			if err != nil {
+				return "", err
+			}
+			placeholders = append(placeholders, q.bind(value))
+		}
+		if operator == "$nin" {
+			if len(placeholders) == 0 {
+				return column + " IS NOT NULL", nil
+			}
+			if nullable {
+				return "(" + column + " NOT IN (" + strings.Join(placeholders, ", ") + ") AND " + column + " IS NOT NULL)", nil
+			}
+			return "(" + column + " NOT IN (" + strings.Join(placeholders, ", ") + ") OR " + column + " IS NULL)", nil
+		}
+		if len(placeholders) == 0 {
+			return column + " IS NULL", nil
+		}
+		if nullable {
+			return "(" + column + " IN (" + strings.Join(placeholders, ", ") + ") OR " + column + " IS NULL)", nil
+		}
+		return column + " IN (" + strings.Join(placeholders, ", ") + ")", nil
+	case "$exists":
+		exists, ok := target.(bool)
+		if !ok {
+			return "", fmt.Errorf("$exists needs a boolean")
+		}
+		if exists {
+			return column + " IS NOT NULL", nil
+		}
+		return column + " IS NULL", nil
+	}
+	return "", fmt.Errorf("unsupported operator %s", operator)
+}
+
+func (s *SQLAdapter) orderBy(sorting interface{}) (string, error) {
+	order, err := orderOf(sorting)
+	if err != nil || len(order) == 0 {
+		return "", err
+	}
+	terms := []string{}
+	for _, key := range order {
+		if _, err := s.column(key.Key); err != nil {
+			return "", err
+		}
+		direction, _ := toFloat(key.Value)
+		if direction < 0 {
+			terms = append(terms, quoteIdentifier(key.Key)+" DESC NULLS LAST")
+		} else {
+			terms = append(terms, quoteIdentifier(key.Key)+" ASC NULLS FIRST")
+		}
+	}
+	return " ORDER BY " + strings.Join(terms, ", "), nil
+}
+
+type sqlQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// selectDocuments locks the rows on Postgres when locked, for FindOneAnd* writes.
+func (s *SQLAdapter) selectDocuments(ctx context.Context, db sqlQuerier, filter interface{}, sorting interface{}, skip *int64, limit *int64, locked bool) ([]Document, error) {
+	criteria, err := ToDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	q := &sqlStatement{dialect: s.Dialect}
+	condition, err := s.where(q, criteria)
+	if err != nil {
+		return nil, err
+	}
+	order, err := s.orderBy(sorting)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(s.columns))
+	for _, column := range s.columns {
+		names = append(names, quoteIdentifier(column.Name))
+	}
+	statement := fmt.Sprintf("SELECT %s FROM %s WHERE %s%s", strings.Join(names, ", "), quoteIdentifier(s.Table), condition, order)
+	if limit != nil && *limit > 0 {
+		statement += " LIMIT " + q.bind(*limit)
+	} else if skip != nil && *skip > 0 && s.Dialect == Postgres {
+		statement += " LIMIT ALL"
+	} else if skip != nil && *skip > 0 {
+		statement += " LIMIT -1"
+	}
+	if skip != nil && *skip > 0 {
+		statement += " OFFSET " + q.bind(*skip)
+	}
+	if locked && s.Dialect == Postgres {
+		statement += " FOR UPDATE"
+	}
+	rows, err := db.QueryContext(ctx, statement, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	documents := []Document{}
+	for rows.Next() {
+		values := make([]interface{}, len(s.columns))
+		pointers := make([]interface{}, len(s.columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		document := Document{}
+		for i, column := range s.columns {
+			value, err := s.fromColumnValue(column.Kind, values[i])
+			if err != nil {
+				return nil, err
+			}
+			if value != nil || column.Kind != idColumn {
+				document[column.Name] = value
+			}
+		}
+		documents = append(documents, document)
+	}
+	return documents, rows.Err()
+}
+
+func (s *SQLAdapter) columnValues(document Document) ([]string, []interface{}, error) {
+	names := make([]string, 0, len(s.columns))
+	values := make([]interface{}, 0, len(s.columns))
+	for _, column := range s.columns {
+		value, err := s.toColumnValue(column.Kind, document[column.Name])
+		if err != nil {
+			return nil, nil, fmt.Errorf("field %s: %w", column.Name, err)
+		}
+		names = append(names, column.Name)
+		values = append(values, value)
+	}
+	return names, values, nil
+}
+
+type sqlExecer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (s *SQLAdapter) insert(ctx context.Context, db sqlExecer, document Document) error {
+	names, values, err := s.columnValues(document)
+	if err != nil {
+		return err
+	}
+	q := &sqlStatement{dialect: s.Dialect}
+	columns := make([]string, 0, len(names))
+	placeholders := make([]string, 0, len(names))
+	for i, name := range names {
+		columns = append(columns, quoteIdentifier(name))
+		placeholders = append(placeholders, q.bind(values[i]))
+	}
+	statement := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoteIdentifier(s.Table), strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	_, err = db.ExecContext(ctx, statement, q.args...)
+	return err
+}
+
+func (s *SQLAdapter) replace(ctx context.Context, db sqlExecer, document Document) error {
+	names, values, err := s.columnValues(document)
+	if err != nil {
+		return err
+	}
+	q := &sqlStatement{dialect: s.Dialect}
+	assignments := make([]string, 0, len(names))
+	var id interface{}
+	for i, name := range names {
+		if name == "_id" {
+			id = values[i]
+			continue
+		}
+		assignments = append(assignments, quoteIdentifier(name)+" = "+q.bind(values[i]))
+	}
+	statement := fmt.Sprintf("UPDATE %s SET %s WHERE %s = %s", quoteIdentifier(s.Table), strings.Join(assignments, ", "), quoteIdentifier("_id"), q.bind(id))
+	_, err = db.ExecContext(ctx, statement, q.args...)
+	return err
+}
+
+func (s *SQLAdapter) remove(ctx context.Context, db sqlExecer, document Document) error {
+	id, err := s.toColumnValue(idColumn, document["_id"])
+	if err != nil {
+		return err
+	}
+	q := &sqlStatement{dialect: s.Dialect}
+	statement := fmt.Sprintf("DELETE FROM %s WHERE %s = %s", quoteIdentifier(s.Table), quoteIdentifier("_id"), q.bind(id))
+	_, err = db.ExecContext(ctx, statement, q.args...)
+	return err
+}
+
+func isDuplicateKey(err error) bool {
+	if err == nil {
+		return false
+	}
+	message := err.Error()
+	return strings.Contains(message, "UNIQUE constraint failed") || strings.Contains(message, "duplicate key value")
+}
+
+func (s *SQLAdapter) Find(ctx context.Context, filter interface{}, opts FindHandlerOptions) (*mongo.Cursor, error) {
+	if opts == nil {
+		opts = options.Find()
+	}
+	documents, err := s.selectDocuments(ctx, s.DB, filter, opts.Sort, opts.Skip, opts.Limit, false)
+	if err != nil {
+		return nil, err
+	}
+	projection, err := projectionOf(opts.Projection)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]interface{}, 0, len(documents))
+	for _, document := range documents {
+		results = append(results, ProjectDocument(document, projection))
+	}
+	return mongo.NewCursorFromDocuments(results, nil, nil)
+}
+
+func (s *SQLAdapter) FindOne(ctx context.Context, filter interface{}, opts GetHandlerOptions) *mongo.SingleResult {
+	if opts == nil {
+		opts = options.FindOne()
+	}
+	limit := int64(1)
+	documents, err := s.selectDocuments(ctx, s.DB, filter, opts.Sort, opts.Skip, &limit, false)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	if len(documents) == 0 {
+		return mongo.NewSingleResultFromDocument(Document{}, mongo.ErrNoDocuments, nil)
+	}
+	projection, err := projectionOf(opts.Projection)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	return mongo.NewSingleResultFromDocument(ProjectDocument(documents[0], projection), nil, nil)
+}
+
+func (s *SQLAdapter) InsertOne(ctx context.Context, document interface{}, opts CreateHandlerOptions) (*mongo.InsertOneResult, error) {
+	record, err := ToDocument(document)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := record["_id"]; !ok {
+		record["_id"] = primitive.NewObjectID()
+	}
+	if err := s.insert(ctx, s.DB, record); err != nil {
+		if isDuplicateKey(err) {
+			return nil, mongo.WriteException{WriteErrors: mongo.WriteErrors{{Index: 0, Code: DuplicateKeyCode, Message: err.Error()}}}
+		}
+		return nil, err
+	}
+	return &mongo.InsertOneResult{InsertedID: record["_id"]}, nil
+}
+
+func (s *SQLAdapter) InsertMany(ctx context.Context, documents []interface{}, opts CreateManyHandlerOptions) (*mongo.InsertManyResult, error) {
+	ordered := opts == nil || opts.Ordered == nil || *opts.Ordered
+	result := &mongo.InsertManyResult{}
+	failures := mongo.BulkWriteException{}
+	for i, document := range documents {
+		record, err := ToDocument(document)
+		if err != nil {
+			return result, err
+		}
+		if _, ok := record["_id"]; !ok {
+			record["_id"] = primitive.NewObjectID()
+		}
+		if err := s.insert(ctx, s.DB, record); err != nil {
+			if !isDuplicateKey(err) {
+				return result, err
+			}
+			failures.WriteErrors = append(failures.WriteErrors, mongo.BulkWriteError{
+				WriteError: mongo.WriteError{Index: i, Code: DuplicateKeyCode, Message: err.Error()},
+			})
+			if ordered {
+				break
+			}
+			continue
+		}
+		result.InsertedIDs = append(result.InsertedIDs, record["_id"])
+	}
+	if len(failures.WriteErrors) > 0 {
+		return result, failures
+	}
+	return result, nil
+}
+
+func (s *SQLAdapter) FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts PatchHandlerOptions) *mongo.SingleResult {
+	if opts == nil {
+		opts = options.FindOneAndUpdate()
+	}
+	changes, err := ToDocument(update)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	projection, err := projectionOf(opts.Projection)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	after := opts.ReturnDocument != nil && *opts.ReturnDocument == options.After
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	defer tx.Rollback()
+	limit := int64(1)
+	documents, err := s.selectDocuments(ctx, tx, filter, opts.Sort, nil, &limit, true)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	var before, record Document
+	if len(documents) == 0 {
+		if opts.Upsert == nil || !*opts.Upsert {
+			return mongo.NewSingleResultFromDocument(Document{}, mongo.ErrNoDocuments, nil)
+		}
+		record, err = upsertDocument(filter, changes)
+		if err == nil {
+			err = s.insert(ctx, tx, record)
+		}
+	} else {
+		before = documents[0]
+		record = cloneDocument(before)
+		err = UpdateDocument(record, changes)
+		if err == nil {
+			err = s.replace(ctx, tx, record)
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		if isDuplicateKey(err) {
+			err = mongo.CommandError{Code: DuplicateKeyCode, Message: err.Error()}
+		}
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	if after {
+		return mongo.NewSingleResultFromDocument(ProjectDocument(record, projection), nil, nil)
+	}
+	if before == nil {
+		return mongo.NewSingleResultFromDocument(Document{}, mongo.ErrNoDocuments, nil)
+	}
+	return mongo.NewSingleResultFromDocument(ProjectDocument(before, projection), nil, nil)
+}
+
+func (s *SQLAdapter) FindOneAndReplace(ctx context.Context, filter interface{}, replacement interface{}, opts UpdateHandlerOptions) *mongo.SingleResult {
+	if opts == nil {
+		opts = options.FindOneAndReplace()
+	}
+	document, err := ToDocument(replacement)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	projection, err := projectionOf(opts.Projection)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	after := opts.ReturnDocument != nil && *opts.ReturnDocument == options.After
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	defer tx.Rollback()
+	limit := int64(1)
+	documents, err := s.selectDocuments(ctx, tx, filter, opts.Sort, nil, &limit, true)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	var before, record Document
+	if len(documents) == 0 {
+		if opts.Upsert == nil || !*opts.Upsert {
+			return mongo.NewSingleResultFromDocument(Document{}, mongo.ErrNoDocuments, nil)
+		}
+		record, err = upsertReplacement(filter, document)
+		if err == nil {
+			err = s.insert(ctx, tx, record)
+		}
+	} else {
+		before = documents[0]
+		record, err = replaceDocument(before, document)
+		if err == nil {
+			err = s.replace(ctx, tx, record)
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		if isDuplicateKey(err) {
+			err = mongo.CommandError{Code: DuplicateKeyCode, Message: err.Error()}
+		}
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	if after {
+		return mongo.NewSingleResultFromDocument(ProjectDocument(record, projection), nil, nil)
+	}
+	if before == nil {
+		return mongo.NewSingleResultFromDocument(Document{}, mongo.ErrNoDocuments, nil)
+	}
+	return mongo.NewSingleResultFromDocument(ProjectDocument(before, projection), nil, nil)
+}
+
+func (s *SQLAdapter) FindOneAndDelete(ctx context.Context, filter interface{}, opts DeleteHandlerOptions) *mongo.SingleResult {
+	if opts == nil {
+		opts = options.FindOneAndDelete()
+	}
+	projection, err := projectionOf(opts.Projection)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	defer tx.Rollback()
+	limit := int64(1)
+	documents, err := s.selectDocuments(ctx, tx, filter, opts.Sort, nil, &limit, true)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	if len(documents) == 0 {
+		return mongo.NewSingleResultFromDocument(Document{}, mongo.ErrNoDocuments, nil)
+	}
+	if err := s.remove(ctx, tx, documents[0]); err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	if err := tx.Commit(); err != nil {
+		return mongo.NewSingleResultFromDocument(Document{}, err, nil)
+	}
+	return mongo.NewSingleResultFromDocument(ProjectDocument(documents[0], projection), nil, nil)
+}
+
+func (s *SQLAdapter) CountDocuments(ctx context.Context, filter interface{}, opts CountHandlerOptions) (int64, error) {
+	criteria, err := ToDocument(filter)
+	if err != nil {
+		return 0, err
+	}
+	q := &sqlStatement{dialect: s.Dialect}
+	condition, err := s.where(q, criteria)
+	if err != nil {
+		return 0, err
+	}
+	statement := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", quoteIdentifier(s.Table), condition)
+	var count int64
+	if err := s.DB.QueryRowContext(ctx, statement, q.args...).Scan(&count); err != nil {
+		return 0, err
+	}
+	if opts != nil && opts.Skip != nil {
+		count -= *opts.Skip
+		if count < 0 {
+			count = 0
+		}
+	}
+	if opts != nil && opts.Limit != nil && *opts.Limit > 0 && count > *opts.Limit {
+		count = *opts.Limit
+	}
+	return count, nil
+}
+
+// EstimatedDocumentCount reads the planner statistics on Postgres.
+func (s *SQLAdapter) EstimatedDocumentCount(ctx context.Context, opts EstimatedCountHandlerOptions) (int64, error) {
+	var count int64
+	if s.Dialect == Postgres {
+		err := s.DB.QueryRowContext(ctx, "SELECT reltuples::bigint FROM pg_class WHERE oid = to_regclass($1)", s.Table).Scan(&count)
+		if err == nil && count >= 0 {
+			return count, nil
+		}
+	}
+	statement := fmt.Sprintf("SELECT COUNT(*) FROM %s", quoteIdentifier(s.Table))
+	err := s.DB.QueryRowContext(ctx, statement).Scan(&count)
+	return count, err
+}
+
+// Aggregate runs a leading $match as a query and the other stages in memory.
+func (s *SQLAdapter) Aggregate(ctx context.Context, pipeline interface{}, opts AggregateHandlerOptions) (*mongo.Cursor, error) {
+	stages, err := ToStages(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var filter interface{} = Document{}
+	if len(stages) > 0 && stages[0].Name == "$match" {
+		filter = stages[0].Value
+		stages = stages[1:]
+	}
+	documents, err := s.selectDocuments(ctx, s.DB, filter, nil, nil, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	results, err := AggregateDocuments(documents, stages)
+	if err != nil {
+		return nil, err
+	}
+	return cursorOf(results)
+}
+
+func (s *SQLAdapter) CreateIndex(ctx context.Context, key string, unique bool) error {
+	if _, err := s.column(key); err != nil {
+		return err
+	}
+	kind := "INDEX"
+	if unique {
+		kind = "UNIQUE INDEX"
+	}
+	name := quoteIdentifier(s.Table + "_" + key + "_idx")
+	statement := fmt.Sprintf("CREATE %s IF NOT EXISTS %s ON %s (%s)", kind, name, quoteIdentifier(s.Table), quoteIdentifier(key))
+	_, err := s.DB.ExecContext(ctx, statement)
+	return err
+}
+
+type SQLStorage struct {
+	DB       *sql.DB
+	Dialect  string
+	mu       sync.Mutex
+	adapters map[string]*SQLAdapter
+}
+
+func NewSQLStorage(db *sql.DB, dialect string) *SQLStorage {
+	return &SQLStorage{DB: db, Dialect: dialect, adapters: make(map[string]*SQLAdapter)}
+}
+
+func (s *SQLStorage) Collection(name string, schema interface{}) Adapter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	adapter, ok := s.adapters[name]
+	if !ok {
+		var err error
+		adapter, err = NewSQLAdapter(context.Background(), s.DB, s.Dialect, name, schema)
+		if err != nil {
+			log.Fatalf("failed to create table %s %s", name, err)
+		}
+		s.adapters[name] = adapter
+	}
+	return adapter
+}
+
+func InitSQL(dialect string) *sql.DB {
+	config := Configuration()
+	url := config.DATABASE.URL
+	if url == "" && dialect == SQLite {
+		name := config.DATABASE.NAME
+		if name == "" {
+			name = "fiber"
+		}
+		url = "file:" + name + ".db"
+	}
+	db, err := sql.Open(dialect, url)
+	if err != nil {
+		log.Fatalf("failed to connect to the database %s", err)
+	}
+	if dialect == SQLite {
+		db.SetMaxOpenConns(1)
+	}
+	err = db.Ping()
+	if err != nil {
+		log.Fatalf("failed to ping the database %s", err)
+	}
+	return db
+}
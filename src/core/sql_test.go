@@ -0,0 +1,186 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type stock struct {
+	ID         primitive.ObjectID `json:"_id" bson:"_id" access:"readonly"`
+	Name       string             `json:"name" bson:"name"`
+	Rank       int64              `json:"rank" bson:"rank"`
+	Price      float64            `json:"price" bson:"price"`
+	Active     bool               `json:"active" bson:"active"`
+	Tags       []string           `json:"tags" bson:"tags"`
+	Archived   bool               `json:"archived" bson:"archived" access:"readonly"`
+	ArchivedAt *time.Time         `json:"archived_at,omitempty" bson:"archived_at,omitempty" access:"readonly"`
+	Version    int64              `json:"version" bson:"version" access:"readonly"`
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at" access:"readonly"`
+	UpdatedAt  time.Time          `json:"updated_at" bson:"updated_at" access:"readonly"`
+}
+
+func newSQLStocks(t *testing.T) *Service {
+	t.Helper()
+	db, err := sql.Open(SQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	adapter, err := NewSQLAdapter(context.Background(), db, SQLite, "stocks", stock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := adapter.CreateIndex(context.Background(), "name", true); err != nil {
+		t.Fatal(err)
+	}
+	return Create().
+		SetName("stocks").
+		SetPath("/stocks").
+		SetEntity(Entity{Ctx: context.Background(), Adapter: adapter}).
+		SetSchema(stock{}).
+		SetQueryable("name", "rank", "price", "active").
+		SetSortable("name", "rank", "price").
+		SetTimestamps().
+		SetVersioning().
+		SetSoftDelete().
+		SetUpsert().
+		SetResource(CRUD[stock, stock]{})
+}
+
+func names(t *testing.T, out interface{}) []string {
+	t.Helper()
+	data, _ := object(t, out)["data"].([]interface{})
+	result := []string{}
+	for _, item := range data {
+		name, _ := object(t, item)["name"].(string)
+		result = append(result, name)
+	}
+	return result
+}
+
+func equalNames(a []string, b ...string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSQLAdapter(t *testing.T) {
+	stocks := newSQLStocks(t)
+	engine := serve(InitApp(), stocks)
+	user := []string{"X-User", "user"}
+
+	ids := map[string]string{}
+	for i, name := range []string{"a", "b", "c", "d"} {
+		body := map[string]interface{}{"name": name, "rank": i + 1, "price": float64(i) + 0.5, "active": i%2 == 0, "tags": []string{name}}
+		status, _, out := send(t, engine, "POST", "/stocks", body, user...)
+		document := object(t, out)
+		if status != fiber.StatusCreated || document["version"] != float64(1) || document["created_at"] == nil {
+			t.Fatalf("create returned %d %v", status, out)
+		}
+		ids[name], _ = document["_id"].(string)
+	}
+	if status, _, out := send(t, engine, "POST", "/stocks", map[string]interface{}{"name": "a"}, user...); status != fiber.StatusConflict {
+		t.Errorf("duplicate create returned %d %v", status, out)
+	}
+
+	status, _, out := send(t, engine, "GET", "/stocks/"+ids["b"], nil, user...)
+	document := object(t, out)
+	tags, _ := document["tags"].([]interface{})
+	if status != fiber.StatusOK || document["rank"] != float64(2) || document["price"] != 1.5 || document["active"] != false || len(tags) != 1 || tags[0] != "b" {
+		t.Fatalf("get returned %d %v", status, out)
+	}
+
+	queries := map[string][]string{
+		"/stocks?$sort[rank]=-1":                              {"d", "c", "b", "a"},
+		"/stocks?rank[$gte]=2&rank[$lt]=4&$sort[rank]=1":      {"b", "c"},
+		"/stocks?name[$in]=a&name[$in]=d&$sort[name]=1":       {"a", "d"},
+		"/stocks?active=true&$sort[price]=-1":                 {"c", "a"},
+		"/stocks?$or[0][name]=b&$or[1][rank]=4&$sort[name]=1": {"b", "d"},
+		"/stocks?$sort[rank]=1&$limit=2&$skip=1":              {"b", "c"},
+	}
+	for path, expected := range queries {
+		status, _, out := send(t, engine, "GET", path, nil, user...)
+		if status != fiber.StatusOK || !equalNames(names(t, out), expected...) {
+			t.Errorf("%s returned %d %v", path, status, out)
+		}
+	}
+
+	status, _, out = send(t, engine, "PATCH", "/stocks/"+ids["a"], map[string]interface{}{"price": 9.5}, user...)
+	if document := object(t, out); status != fiber.StatusOK || document["price"] != 9.5 || document["name"] != "a" || document["version"] != float64(2) {
+		t.Errorf("patch returned %d %v", status, out)
+	}
+	if status, _, out := send(t, engine, "PATCH", "/stocks/"+ids["a"], map[string]interface{}{"name": "b"}, user...); status != fiber.StatusConflict {
+		t.Errorf("patch to a duplicate name returned %d %v", status, out)
+	}
+	if status, _, out := send(t, engine, "PATCH", "/stocks/"+ids["a"], map[string]interface{}{"price": 1}, append(user, "If-Match", `"1"`)...); status != fiber.StatusPreconditionFailed {
+		t.Errorf("patch of a stale version returned %d %v", status, out)
+	}
+
+	id := primitive.NewObjectID().Hex()
+	status, _, out = send(t, engine, "PUT", "/stocks/"+id, map[string]interface{}{"name": "e", "rank": 5}, user...)
+	if document := object(t, out); status != fiber.StatusCreated || document["_id"] != id || document["version"] != float64(1) {
+		t.Fatalf("upsert returned %d %v", status, out)
+	}
+	status, _, out = send(t, engine, "PUT", "/stocks/"+id, map[string]interface{}{"name": "f"}, user...)
+	if document := object(t, out); status != fiber.StatusOK || document["name"] != "f" || document["rank"] != float64(0) || document["version"] != float64(2) {
+		t.Errorf("update returned %d %v", status, out)
+	}
+
+	if status, _, out := send(t, engine, "DELETE", "/stocks/"+ids["c"], nil, user...); status != fiber.StatusOK {
+		t.Fatalf("delete returned %d %v", status, out)
+	}
+	if status, _, out := send(t, engine, "GET", "/stocks/"+ids["c"], nil, user...); status != fiber.StatusNotFound {
+		t.Errorf("get of a deleted document returned %d %v", status, out)
+	}
+	status, _, out = send(t, engine, "GET", "/stocks?$sort[name]=1", nil, user...)
+	if page := object(t, out); status != fiber.StatusOK || !equalNames(names(t, out), "a", "b", "d", "f") || page["total"] != float64(4) {
+		t.Errorf("find after delete returned %d %v", status, out)
+	}
+	stored, err := Typed[stock](stocks.Handler.WithArchived()).Get(Document{"name": "c"}, options.FindOne())
+	if err != nil || !stored.Archived || stored.ArchivedAt == nil || stored.ArchivedAt.IsZero() {
+		t.Errorf("archived %+v %v", stored, err)
+	}
+
+	if status, _, out := send(t, engine, "GET", "/stocks/"+primitive.NewObjectID().Hex(), nil, user...); status != fiber.StatusNotFound {
+		t.Errorf("get of a missing document returned %d %v", status, out)
+	}
+}
+
+func TestSQLAdapterValues(t *testing.T) {
+	stocks := newSQLStocks(t)
+	at := time.Date(2024, 2, 29, 12, 30, 15, 123000000, time.UTC)
+	created, err := Typed[stock](stocks.Handler).Create(Document{"name": "a", "rank": int64(1), "tags": []string{"x", "y"}, "archived_at": at}, options.InsertOne())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := Typed[stock](stocks.Handler.WithArchived()).Get(Document{"_id": created.ID}, options.FindOne())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ArchivedAt == nil || !stored.ArchivedAt.Equal(at) || len(stored.Tags) != 2 || stored.Tags[1] != "y" {
+		t.Errorf("stored %+v", stored)
+	}
+
+	if _, err := Typed[stock](stocks.Handler).Patch(Document{"_id": created.ID}, Operators{"$inc": Document{"rank": int64(2)}}, options.FindOneAndUpdate()); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err = Typed[stock](stocks.Handler.WithArchived()).Get(Document{"_id": created.ID}, options.FindOne()); err != nil || stored.Rank != 3 {
+		t.Errorf("incremented %+v %v", stored, err)
+	}
+	if _, err := Typed[stock](stocks.Handler).Get(Document{"missing": 1}, options.FindOne()); err == nil {
+		t.Error("filter on an unknown column succeeded")
+	}
+}
@@ -0,0 +1,28 @@
+package core
+
+import (
+	"time"
+)
+
+const (
+	CreatedAtField = "created_at"
+	UpdatedAtField = "updated_at"
+)
+
+// SetTimestamps sets created_at on insert and updated_at on every write.
+func (s *Service) SetTimestamps() *Service {
+	s.Timestamps = true
+	return s
+}
+
+func stampCreated(document Document, now time.Time) {
+	document[CreatedAtField] = now
+	document[UpdatedAtField] = now
+}
+
+func stampPatch(operators Operators, now time.Time) Operators {
+	operators = operators.Omit(CreatedAtField, UpdatedAtField)
+	operators.add("$set", UpdatedAtField, now)
+	operators.add("$setOnInsert", CreatedAtField, now)
+	return operators
+}
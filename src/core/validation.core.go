@@ -0,0 +1,282 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// BindingTag declares the go-playground/validator rules, plus enum, of a field.
+const BindingTag = "binding"
+
+// Enum is a type with a closed set of values, checked by the enum rule.
+type Enum interface {
+	Values() []string
+}
+
+// FieldError is a field failing a rule, by its dotted json path.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.SetTagName(BindingTag)
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		switch name {
+		case "-":
+			return ""
+		case "":
+			return field.Name
+		}
+		return name
+	})
+	v.RegisterValidation("enum", func(fl validator.FieldLevel) bool {
+		enum, ok := fl.Field().Interface().(Enum)
+		if !ok {
+			return false
+		}
+		value := fl.Field().String()
+		for _, allowed := range enum.Values() {
+			if value == allowed {
+				return true
+			}
+		}
+		return false
+	})
+	return v
+}
+
+func invalid(errs []FieldError) *ServerError {
+	e := Unprocessable("invalid payload")
+	e.Errors = errs
+	return e
+}
+
+// Validate checks the binding rules of a value, failing with a 422 ServerError.
+func Validate(v interface{}) error {
+	if errs := fieldErrors(v); len(errs) > 0 {
+		return invalid(errs)
+	}
+	return nil
+}
+
+// Decode decodes the JSON data at field of a body into target and checks it.
+func Decode(field string, data interface{}, target interface{}) error {
+	if errs := decode(data, target, nil); len(errs) > 0 {
+		return invalid(prefixed(errs, field))
+	}
+	return nil
+}
+
+func fieldErrors(v interface{}) []FieldError {
+	err := validate.Struct(v)
+	var failures validator.ValidationErrors
+	if !errors.As(err, &failures) {
+		return nil
+	}
+	errs := make([]FieldError, 0, len(failures))
+	for _, failure := range failures {
+		_, field, _ := strings.Cut(failure.Namespace(), ".")
+		errs = append(errs, FieldError{
+			Field:   field,
+			Rule:    failure.Tag(),
+			Param:   failure.Param(),
+			Message: field + " " + ruleMessage(failure),
+		})
+	}
+	return errs
+}
+
+func ruleMessage(failure validator.FieldError) string {
+	param := failure.Param()
+	unit := ""
+	switch failure.Kind() {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = " items"
+	}
+	switch failure.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min", "gte":
+		return "must be at least " + param + unit
+	case "max", "lte":
+		return "must be at most " + param + unit
+	case "len":
+		return "must be exactly " + param + unit
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(param), ", ")
+	case "enum":
+		if enum, ok := failure.Value().(Enum); ok {
+			return "must be one of " + strings.Join(enum.Values(), ", ")
+		}
+	}
+	return fmt.Sprintf("must satisfy %s", failure.Tag())
+}
+
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	}
+	return "a number"
+}
+
+func decode(data interface{}, target interface{}, present map[string]bool) []FieldError {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return []FieldError{{Rule: "type", Message: err.Error()}}
+	}
+	var errs []FieldError
+	mistyped := map[string]bool{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if err := decoder.Decode(target); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			return []FieldError{{Rule: "type", Message: err.Error()}}
+		}
+		if typeErr.Field == "" {
+			return []FieldError{{Rule: "type", Message: "payload must be " + jsonType(typeErr.Type)}}
+		}
+		mistyped[typeErr.Field] = true
+		errs = append(errs, FieldError{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: typeErr.Field + " must be " + jsonType(typeErr.Type),
+		})
+	}
+	for _, e := range fieldErrors(target) {
+		if mistyped[e.Field] || (present != nil && !present[rootField(e.Field)]) {
+			continue
+		}
+		errs = append(errs, e)
+	}
+	return errs
+}
+
+func prefixed(errs []FieldError, prefix string) []FieldError {
+	if prefix == "" {
+		return errs
+	}
+	for i := range errs {
+		field, subject := prefix, "payload"
+		if errs[i].Field != "" {
+			field, subject = prefix+"."+errs[i].Field, errs[i].Field
+		}
+		errs[i].Field = field
+		errs[i].Message = field + strings.TrimPrefix(errs[i].Message, subject)
+	}
+	return errs
+}
+
+type partialSchema struct {
+	schema interface{}
+}
+
+// Partial marks a request schema whose rules only apply to present fields.
+func Partial(schema interface{}) interface{} {
+	return partialSchema{schema: schema}
+}
+
+// SetRequestSchema sets the schema the payloads of a method are validated
+// against. JSON Patch operations and Operators are not validated.
+func (s *Service) SetRequestSchema(method string, schema interface{}) *Service {
+	if s.Requests == nil {
+		s.Requests = make(map[string]interface{})
+	}
+	s.Requests[method] = schema
+	return s
+}
+
+func (s *Service) validate(method string, hc *HookContext) error {
+	schema, ok := s.Requests[method]
+	if _, operators := hc.Data.(Operators); !ok || operators {
+		return nil
+	}
+	partial, isPartial := schema.(partialSchema)
+	if isPartial {
+		schema = partial.schema
+	}
+	t := reflect.TypeOf(schema)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	check := func(data interface{}) []FieldError {
+		var present map[string]bool
+		if isPartial {
+			data, present = changes(data)
+		}
+		return decode(data, reflect.New(t).Interface(), present)
+	}
+
+	if unparsed(hc) {
+		return BadRequest("payload must be json")
+	}
+	data := hc.Data
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	var errs []FieldError
+	if items, ok := data.([]interface{}); ok {
+		switch {
+		case method == MethodCreate && s.Multi[method]:
+			for i, item := range items {
+				errs = append(errs, prefixed(check(item), strconv.Itoa(i))...)
+			}
+		case method == MethodPatch:
+		default:
+			errs = check(data)
+		}
+	} else {
+		errs = check(data)
+	}
+	if len(errs) > 0 {
+		return invalid(errs)
+	}
+	return nil
+}
+
+func changes(data interface{}) (interface{}, map[string]bool) {
+	document, ok := data.(map[string]interface{})
+	if !ok {
+		if body, err := json.Marshal(data); err == nil {
+			json.Unmarshal(body, &document)
+		}
+		if document == nil {
+			return data, nil
+		}
+	}
+	fields := map[string]interface{}{}
+	for key, value := range document {
+		if !strings.HasPrefix(key, "$") && !strings.Contains(key, ".") {
+			fields[key] = value
+		}
+	}
+	present := make(map[string]bool, len(fields))
+	for key := range fields {
+		present[key] = true
+	}
+	return fields, present
+}
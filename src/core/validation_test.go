@@ -0,0 +1,123 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type level string
+
+func (level) Values() []string { return []string{"low", "high"} }
+
+type memberRequest struct {
+	Name    string  `json:"name" binding:"required"`
+	Email   string  `json:"email" binding:"required,email"`
+	Secret  string  `json:"secret" binding:"omitempty,min=8"`
+	Level   level   `json:"level" binding:"omitempty,enum"`
+	Age     int64   `json:"age" binding:"gte=0"`
+	Address address `json:"address"`
+}
+
+type address struct {
+	City string `json:"city" binding:"required"`
+}
+
+type member struct {
+	ID    primitive.ObjectID `json:"_id" bson:"_id"`
+	Name  string             `json:"name" bson:"name"`
+	Email string             `json:"email" bson:"email"`
+	Age   int64              `json:"age" bson:"age"`
+}
+
+func fieldRules(err error) map[string]string {
+	var serverError *ServerError
+	if !errors.As(err, &serverError) || serverError.Status != fiber.StatusUnprocessableEntity {
+		return nil
+	}
+	rules := map[string]string{}
+	for _, e := range serverError.Errors {
+		rules[e.Field] = e.Rule
+	}
+	return rules
+}
+
+func TestValidate(t *testing.T) {
+	err := Validate(memberRequest{Email: "nope", Secret: "short", Level: "mid", Age: -1})
+	rules := map[string]string{"name": "required", "email": "email", "secret": "min", "level": "enum", "age": "gte", "address.city": "required"}
+	if got := fieldRules(err); !reflect.DeepEqual(got, rules) {
+		t.Fatalf("validation failed with %v", got)
+	}
+	var serverError *ServerError
+	errors.As(err, &serverError)
+	messages := map[string]string{
+		"secret": "secret must be at least 8 characters",
+		"level":  "level must be one of low, high",
+		"email":  "email must be a valid email address",
+	}
+	for _, e := range serverError.Errors {
+		if message, ok := messages[e.Field]; ok && e.Message != message {
+			t.Errorf("%s failed with %q", e.Field, e.Message)
+		}
+	}
+	if err := Validate(memberRequest{Name: "Ada", Email: "ada@example.com", Address: address{City: "London"}}); err != nil {
+		t.Errorf("valid request failed with %v", err)
+	}
+
+	var target memberRequest
+	err = Decode("data", map[string]interface{}{"name": "Ada", "email": "ada@example.com", "age": "old", "address": map[string]interface{}{"city": "London"}}, &target)
+	if got := fieldRules(err); !reflect.DeepEqual(got, map[string]string{"data.age": "type"}) {
+		t.Errorf("decode failed with %v", got)
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	members := newService("members", member{}).
+		SetRequestSchema(MethodCreate, memberRequest{}).
+		SetRequestSchema(MethodPatch, Partial(memberRequest{})).
+		SetMulti(MethodCreate).
+		SetResource(CRUD[member, member]{}).
+		AddProtectedRoute(MethodCreate, CRUD[member, member]{}.Create)
+	engine := serve(InitApp(), members)
+	user := []string{"X-User", "user"}
+	admin := []string{"X-User", "admin", "X-Role", AdminRole}
+
+	status, _, out := send(t, engine, "POST", "/members", map[string]interface{}{"email": "nope", "age": -1}, user...)
+	errs, _ := object(t, out)["errors"].([]interface{})
+	if status != fiber.StatusUnprocessableEntity || len(errs) != 4 {
+		t.Fatalf("invalid create returned %d %v", status, out)
+	}
+	for _, e := range errs {
+		if failure := object(t, e); failure["field"] == "" || failure["rule"] == "" || failure["message"] == "" {
+			t.Errorf("field error %v", failure)
+		}
+	}
+	if status, _, out := send(t, engine, "POST", "/members", []byte(`"member"`), user...); status != fiber.StatusUnprocessableEntity {
+		t.Errorf("create of a string returned %d %v", status, out)
+	}
+
+	valid := map[string]interface{}{"name": "Ada", "email": "ada@example.com", "address": map[string]interface{}{"city": "London"}}
+	status, _, out = send(t, engine, "POST", "/members", valid, user...)
+	if status != fiber.StatusCreated {
+		t.Fatalf("create returned %d %v", status, out)
+	}
+	path := "/members/" + object(t, out)["_id"].(string)
+
+	if status, _, out := send(t, engine, "PATCH", path, map[string]interface{}{"age": 30}, user...); status != fiber.StatusOK {
+		t.Errorf("partial patch returned %d %v", status, out)
+	}
+	status, _, out = send(t, engine, "PATCH", path, map[string]interface{}{"email": "nope", "name": ""}, user...)
+	errs, _ = object(t, out)["errors"].([]interface{})
+	if status != fiber.StatusUnprocessableEntity || len(errs) != 2 {
+		t.Errorf("invalid patch returned %d %v", status, out)
+	}
+
+	status, _, out = send(t, engine, "POST", "/members", []interface{}{valid, map[string]interface{}{"name": "Grace"}}, admin...)
+	errs, _ = object(t, out)["errors"].([]interface{})
+	if status != fiber.StatusUnprocessableEntity || len(errs) != 2 || object(t, errs[0])["field"] != "1.email" {
+		t.Errorf("invalid multi create returned %d %v", status, out)
+	}
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const VersionField = "version"
+
+var (
+	ErrVersionMismatch = errors.New("version mismatch")
+	ErrWeakETag        = errors.New("weak entity tags never match If-Match")
+)
+
+// SetVersioning keeps a version on every document, checked by If-Match and
+// If-None-Match.
+func (s *Service) SetVersioning() *Service {
+	s.Versioning = true
+	return s
+}
+
+// IfVersion returns a copy of the handler only writing documents at version.
+func (h Handler) IfVersion(version int64) Handler {
+	if h.service == nil {
+		return h
+	}
+	scope := h.scope
+	scope.version = &version
+	return h.service.newHandler(h.ctx, scope)
+}
+
+func atVersion(filter interface{}, version *int64) interface{} {
+	if version == nil {
+		return filter
+	}
+	condition := bson.M{VersionField: *version}
+	if filter == nil {
+		return condition
+	}
+	return bson.M{"$and": primitive.A{filter, condition}}
+}
+
+func versionPatch(operators Operators) Operators {
+	operators = operators.Omit(VersionField)
+	operators.add("$inc", VersionField, int64(1))
+	return operators
+}
+
+func documentVersion(document Document) (int64, bool) {
+	version, ok := toFloat(document[VersionField])
+	return int64(version), ok
+}
+
+func ETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ParseETag reads the version of a strong entity tag.
+func ParseETag(tag string) (int64, error) {
+	tag = strings.TrimSpace(tag)
+	if strings.HasPrefix(tag, "W/") {
+		return 0, ErrWeakETag
+	}
+	version, err := strconv.ParseInt(strings.Trim(tag, `"`), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid entity tag %s", tag)
+	}
+	return version, nil
+}
+
+// etagListed compares the tags of If-None-Match weakly.
+func etagListed(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) ifMatch(c *fiber.Ctx, method string, h Handler) (Handler, error) {
+	header := c.Get(fiber.HeaderIfMatch)
+	if !s.Versioning || header == "" || strings.TrimSpace(header) == "*" {
+		return h, nil
+	}
+	switch method {
+	case MethodPatch, MethodUpdate, MethodDelete:
+		version, err := ParseETag(header)
+		if errors.Is(err, ErrWeakETag) {
+			return h, PreconditionFailed(err.Error())
+		}
+		if err != nil {
+			return h, BadRequest(err.Error())
+		}
+		return h.IfVersion(version), nil
+	}
+	return h, nil
+}
+
+func (s *Service) tag(c *fiber.Ctx, method string, result interface{}) bool {
+	if !s.Versioning || result == nil {
+		return false
+	}
+	document, err := ToDocument(result)
+	if err != nil {
+		return false
+	}
+	version, ok := documentVersion(document)
+	if !ok {
+		return false
+	}
+	etag := ETag(version)
+	c.Set(fiber.HeaderETag, etag)
+	return method == MethodGet && etagListed(c.Get(fiber.HeaderIfNoneMatch), etag)
+}
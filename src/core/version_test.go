@@ -0,0 +1,44 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestETags(t *testing.T) {
+	_, _, engine, id := newAccounts(t)
+	path := "/accounts/" + id
+
+	status, header, out := send(t, engine, "GET", path, nil, "X-User", "user")
+	if status != fiber.StatusOK || header.Get(fiber.HeaderETag) != `"1"` {
+		t.Fatalf("get returned %d %v %v", status, header, out)
+	}
+	for _, tag := range []string{`"1"`, `W/"1"`, `"0", "1"`, "*"} {
+		if status, _, out := send(t, engine, "GET", path, nil, "X-User", "user", fiber.HeaderIfNoneMatch, tag); status != fiber.StatusNotModified {
+			t.Errorf("get with If-None-Match %s returned %d %v", tag, status, out)
+		}
+	}
+	if status, _, out := send(t, engine, "GET", path, nil, "X-User", "user", fiber.HeaderIfNoneMatch, `"0"`); status != fiber.StatusOK {
+		t.Errorf("get with a stale If-None-Match returned %d %v", status, out)
+	}
+
+	preconditions := map[string]int{
+		`"0"`:   fiber.StatusPreconditionFailed,
+		`W/"1"`: fiber.StatusPreconditionFailed,
+		`"one"`: fiber.StatusBadRequest,
+		`"1"`:   fiber.StatusOK,
+	}
+	for _, tag := range []string{`"0"`, `W/"1"`, `"one"`, `"1"`} {
+		status, header, out := send(t, engine, "PATCH", path, map[string]interface{}{"name": "Grace"}, "X-User", "user", fiber.HeaderIfMatch, tag)
+		if status != preconditions[tag] {
+			t.Errorf("patch with If-Match %s returned %d %v", tag, status, out)
+		}
+		if status == fiber.StatusOK && header.Get(fiber.HeaderETag) != `"2"` {
+			t.Errorf("patch returned the ETag %s", header.Get(fiber.HeaderETag))
+		}
+	}
+	if status, _, out := send(t, engine, "DELETE", path, nil, "X-User", "user", fiber.HeaderIfMatch, `"1"`); status != fiber.StatusPreconditionFailed {
+		t.Errorf("delete with a stale If-Match returned %d %v", status, out)
+	}
+}